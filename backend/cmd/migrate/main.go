@@ -0,0 +1,95 @@
+// migrate 管理数据库结构版本，与服务使用同一份配置
+//
+//	go run ./cmd/migrate up          执行全部未执行的迁移
+//	go run ./cmd/migrate down [-n N] 回滚最近 N 个迁移（默认 1）
+//	go run ./cmd/migrate status      查看各版本执行情况
+package main
+
+import (
+	"flag"
+	"fmt"
+	"note-system/config"
+	"note-system/internal/repository"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: migrate [-config 路径] up | down [-n 步数] | status")
+	os.Exit(2)
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	cfgPath := flag.String("config", "", "配置文件路径，默认同服务启动")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	var paths []string
+	if *cfgPath != "" {
+		paths = []string{*cfgPath}
+	}
+	cfg, err := config.Load(paths...)
+	if err != nil {
+		fatal("加载配置失败：%v", err)
+	}
+	db, err := repository.OpenDB(cfg.Database.Driver, cfg.Database.DSN(cfg.Mysql))
+	if err != nil {
+		fatal("数据库连接失败：%v", err)
+	}
+	m, err := repository.NewMigrator(db)
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		ran, err := m.Up()
+		for _, mg := range ran {
+			fmt.Printf("up   %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			fatal("%v", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("没有需要执行的迁移")
+		}
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		n := fs.Int("n", 1, "回滚的迁移个数")
+		fs.Parse(flag.Args()[1:])
+		if *n <= 0 {
+			fatal("-n 必须大于 0")
+		}
+		ran, err := m.Down(*n)
+		for _, mg := range ran {
+			fmt.Printf("down %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			fatal("%v", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("没有可回滚的迁移")
+		}
+	case "status":
+		list, err := m.Status()
+		if err != nil {
+			fatal("%v", err)
+		}
+		for _, st := range list {
+			at := "未执行"
+			if st.AppliedAt != nil {
+				at = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-24s %s\n", st.Version, st.Name, at)
+		}
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,528 @@
+// rageval 用标准问题集离线评测检索效果：把示例笔记按服务相同的方式切分、向量化后写入内存索引，
+// 逐题检索并统计 recall@k、MRR 与 nDCG，可作为 CI 门禁比较切分、向量化或阈值改动的影响
+//
+//	go run ./cmd/rageval                                   使用内置问题集与 fixture.CNNotes
+//	go run ./cmd/rageval -golden q.jsonl -notes notes.yaml 自定义问题集与笔记
+//	go run ./cmd/rageval -min-recall 0.8 -min-mrr 0.6      指标低于阈值时以状态码 1 退出
+//
+// 与服务端相同经 rag.EmbedBatch 向量化；默认使用本地向量（不配置 embedding_url），全程不访问网络
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"note-system/config"
+	"note-system/internal/fixture"
+	"note-system/internal/rag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml"
+)
+
+// embedBatchSize 单次向量化的文本数，避免远程向量服务请求过大
+const embedBatchSize = 64
+
+// Question 标准问题。Notes 为期望命中的笔记编号；Fragments 为期望命中的片段，
+// 写片段 ID（“笔记编号#序号”）或片段中的一段原文，切分方式改变后原文写法依然有效
+type Question struct {
+	ID        string   `json:"id" yaml:"id"`
+	Question  string   `json:"question" yaml:"question"`
+	Notes     []int64  `json:"notes" yaml:"notes"`
+	Fragments []string `json:"fragments" yaml:"fragments"`
+}
+
+// Note 评测用的笔记，ID 为空时按顺序从 1 编号
+type Note struct {
+	ID      int64  `json:"id" yaml:"id"`
+	Title   string `json:"title" yaml:"title"`
+	Content string `json:"content" yaml:"content"`
+}
+
+type fragment struct {
+	id      string
+	noteID  int64
+	content string
+}
+
+// Metrics 一组问题在某一粒度上的平均指标
+type Metrics struct {
+	Questions int                `json:"questions"`
+	Recall    map[string]float64 `json:"recall"`
+	MRR       float64            `json:"mrr"`
+	NDCG      float64            `json:"ndcg"`
+}
+
+// Result 单个问题的评测结果，Rank 为第一个命中的名次（从 1 开始），未命中为 0
+type Result struct {
+	ID        string   `json:"id"`
+	NoteRank  int      `json:"note_rank,omitempty"`
+	FragRank  int      `json:"frag_rank,omitempty"`
+	Retrieved []string `json:"retrieved"`
+	Missed    []string `json:"missed,omitempty"`
+}
+
+type Report struct {
+	Notes     int       `json:"notes"`
+	Fragments int       `json:"fragments"`
+	Embedding string    `json:"embedding"`
+	K         []int     `json:"k"`
+	Threshold float64   `json:"threshold"`
+	Note      *Metrics  `json:"note,omitempty"`
+	Fragment  *Metrics  `json:"fragment,omitempty"`
+	Results   []*Result `json:"results"`
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: rageval [-golden 路径] [-notes 路径] [-k 1,3,5,10] [-threshold 0] [-min-recall x] [-min-mrr x] [-json] [-v]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	goldenPath := flag.String("golden", "testdata/rageval/cn_notes.yaml", "标准问题集，.yaml/.yml 或 .jsonl")
+	notesPath := flag.String("notes", "", "评测用笔记，.yaml/.yml 或 .jsonl；为空时使用 fixture.CNNotes")
+	cfgPath := flag.String("config", "", "读取该配置文件的 rag 段（向量服务、维度等）；为空时使用默认配置")
+	embeddingURL := flag.String("embedding-url", "", "覆盖 rag.embedding_url，为空且配置中也未设置时使用本地向量")
+	kList := flag.String("k", "1,3,5,10", "统计 recall@k 的 k，逗号分隔；nDCG 按其中最大值计算")
+	threshold := flag.Float64("threshold", 0, "丢弃相似度低于该值的片段，用于评估 rag.similarity_threshold")
+	minRecall := flag.Float64("min-recall", 0, "最大 k 处的召回率低于该值时退出码为 1")
+	minMRR := flag.Float64("min-mrr", 0, "MRR 低于该值时退出码为 1")
+	asJSON := flag.Bool("json", false, "以 JSON 输出报告")
+	verbose := flag.Bool("v", false, "输出每个问题的检索结果")
+	flag.Usage = usage
+	flag.Parse()
+
+	ks, err := parseKs(*kList)
+	if err != nil {
+		fatal("-k: %v", err)
+	}
+	cfg := config.Default()
+	if *cfgPath != "" {
+		loaded, err := config.Load(*cfgPath)
+		if err != nil {
+			fatal("加载配置失败：%v", err)
+		}
+		cfg = *loaded
+	}
+	ragCfg := cfg.Rag
+	if *embeddingURL != "" {
+		ragCfg.EmbeddingURL = *embeddingURL
+	}
+
+	questions, err := loadQuestions(*goldenPath)
+	if err != nil {
+		fatal("读取问题集失败：%v", err)
+	}
+	notes, err := loadNotes(*notesPath)
+	if err != nil {
+		fatal("读取笔记失败：%v", err)
+	}
+
+	ctx := context.Background()
+	frags, index, err := buildIndex(ctx, ragCfg, notes)
+	if err != nil {
+		fatal("建立索引失败：%v", err)
+	}
+	report := evaluate(ctx, ragCfg, index, frags, questions, ks, *threshold)
+	report.Notes = len(notes)
+	report.Embedding = "local"
+	if ragCfg.EmbeddingURL != "" {
+		report.Embedding = ragCfg.EmbeddingURL
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report, *verbose)
+	}
+
+	// 门禁：每个粒度分别检查
+	failed := false
+	for _, level := range levels(report) {
+		name, m := level.name, level.m
+		if r := m.Recall[strconv.Itoa(ks[len(ks)-1])]; r < *minRecall {
+			fmt.Fprintf(os.Stderr, "%s recall@%d %.3f 低于 %.3f\n", name, ks[len(ks)-1], r, *minRecall)
+			failed = true
+		}
+		if m.MRR < *minMRR {
+			fmt.Fprintf(os.Stderr, "%s MRR %.3f 低于 %.3f\n", name, m.MRR, *minMRR)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func parseKs(s string) ([]int, error) {
+	ks := make([]int, 0)
+	for _, part := range strings.Split(s, ",") {
+		k, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || k <= 0 {
+			return nil, fmt.Errorf("不合法的 k %q", part)
+		}
+		ks = append(ks, k)
+	}
+	sort.Ints(ks)
+	return ks, nil
+}
+
+// decodeFile 按扩展名解析 YAML（顶层为 key 对应的列表）或 JSONL（每行一个对象）
+func decodeFile[T any](path, key string) ([]T, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc map[string][]T
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		return doc[key], nil
+	case ".jsonl":
+		out := make([]T, 0)
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" || strings.HasPrefix(text, "//") {
+				continue
+			}
+			var v T
+			if err := json.Unmarshal([]byte(text), &v); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", line, err)
+			}
+			out = append(out, v)
+		}
+		return out, sc.Err()
+	default:
+		return nil, fmt.Errorf("不支持的文件类型 %s", path)
+	}
+}
+
+func loadQuestions(path string) ([]Question, error) {
+	qs, err := decodeFile[Question](path, "questions")
+	if err != nil {
+		return nil, err
+	}
+	for i, q := range qs {
+		if strings.TrimSpace(q.Question) == "" {
+			return nil, fmt.Errorf("第 %d 个问题为空", i+1)
+		}
+		if len(q.Notes) == 0 && len(q.Fragments) == 0 {
+			return nil, fmt.Errorf("问题 %q 没有期望的笔记或片段", q.Question)
+		}
+		if q.ID == "" {
+			qs[i].ID = strconv.Itoa(i + 1)
+		}
+	}
+	return qs, nil
+}
+
+func loadNotes(path string) ([]Note, error) {
+	if path == "" {
+		notes := make([]Note, len(fixture.CNNotes))
+		for i, n := range fixture.CNNotes {
+			notes[i] = Note{ID: int64(i + 1), Title: n.Title, Content: n.Content}
+		}
+		return notes, nil
+	}
+	notes, err := decodeFile[Note](path, "notes")
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		if notes[i].ID == 0 {
+			notes[i].ID = int64(i + 1)
+		}
+	}
+	return notes, nil
+}
+
+// buildIndex 与服务端索引笔记的方式一致：SplitMarkdown 切分，逐片段向量化
+func buildIndex(ctx context.Context, cfg config.RagConfig, notes []Note) (map[string]fragment, *rag.MemoryIndex, error) {
+	frags := make([]fragment, 0)
+	for _, n := range notes {
+		for i, c := range rag.SplitMarkdown(n.Content) {
+			frags = append(frags, fragment{id: fmt.Sprintf("%d#%d", n.ID, i), noteID: n.ID, content: c.Content})
+		}
+	}
+	texts := make([]string, len(frags))
+	for i, f := range frags {
+		texts[i] = f.content
+	}
+	vecs, err := embedAll(ctx, cfg, texts)
+	if err != nil {
+		return nil, nil, err
+	}
+	index := rag.NewMemoryIndex()
+	byID := make(map[string]fragment, len(frags))
+	for i, f := range frags {
+		index.Upsert(f.id, vecs[i], nil)
+		byID[f.id] = f
+	}
+	return byID, index, nil
+}
+
+func embedAll(ctx context.Context, cfg config.RagConfig, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		vecs, err := rag.EmbedBatch(ctx, cfg, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+func evaluate(ctx context.Context, cfg config.RagConfig, index *rag.MemoryIndex, frags map[string]fragment, questions []Question, ks []int, threshold float64) *Report {
+	maxK := ks[len(ks)-1]
+	texts := make([]string, len(questions))
+	for i, q := range questions {
+		texts[i] = q.Question
+	}
+	vecs, err := embedAll(ctx, cfg, texts)
+	if err != nil {
+		fatal("问题向量化失败：%v", err)
+	}
+	report := &Report{Fragments: len(frags), K: ks, Threshold: threshold, Results: make([]*Result, 0, len(questions))}
+	var noteScores, fragScores []score
+	for i, q := range questions {
+		// 多取一些片段，保证去重到笔记后仍有 maxK 条
+		res := index.Query(vecs[i], maxK*4)
+		ranked := make([]fragment, 0, len(res.Matches))
+		for _, m := range res.Matches {
+			if float64(m.Score) >= threshold {
+				ranked = append(ranked, frags[m.ID])
+			}
+		}
+		r := &Result{ID: q.ID}
+		if len(q.Notes) > 0 {
+			hits, noteIDs := noteHits(ranked, q.Notes, maxK)
+			s := scoreHits(hits, len(q.Notes), ks)
+			noteScores = append(noteScores, s)
+			r.NoteRank = s.firstRank
+			r.Retrieved = noteIDs
+			r.Missed = append(r.Missed, missedNotes(q.Notes, noteIDs)...)
+		}
+		if len(q.Fragments) > 0 {
+			if len(ranked) > maxK {
+				ranked = ranked[:maxK]
+			}
+			hits, covered := fragHits(ranked, q.Fragments)
+			s := scoreHits(hits, len(q.Fragments), ks)
+			fragScores = append(fragScores, s)
+			r.FragRank = s.firstRank
+			if r.Retrieved == nil {
+				for _, f := range ranked {
+					r.Retrieved = append(r.Retrieved, f.id)
+				}
+			}
+			for j, spec := range q.Fragments {
+				if !covered[j] {
+					r.Missed = append(r.Missed, spec)
+				}
+			}
+		}
+		report.Results = append(report.Results, r)
+	}
+	report.Note = average(noteScores, ks)
+	report.Fragment = average(fragScores, ks)
+	return report
+}
+
+// noteHits 把片段排名去重为笔记排名，返回前 maxK 个笔记各自命中的期望笔记
+func noteHits(ranked []fragment, expected []int64, maxK int) ([][]int, []string) {
+	want := make(map[int64]int, len(expected))
+	for i, id := range expected {
+		want[id] = i
+	}
+	seen := make(map[int64]bool)
+	hits := make([][]int, 0, maxK)
+	ids := make([]string, 0, maxK)
+	for _, f := range ranked {
+		if seen[f.noteID] {
+			continue
+		}
+		seen[f.noteID] = true
+		var hit []int
+		if i, ok := want[f.noteID]; ok {
+			hit = []int{i}
+		}
+		hits = append(hits, hit)
+		ids = append(ids, strconv.FormatInt(f.noteID, 10))
+		if len(hits) == maxK {
+			break
+		}
+	}
+	return hits, ids
+}
+
+func missedNotes(expected []int64, retrieved []string) []string {
+	got := make(map[string]bool, len(retrieved))
+	for _, id := range retrieved {
+		got[id] = true
+	}
+	out := make([]string, 0)
+	for _, id := range expected {
+		if s := strconv.FormatInt(id, 10); !got[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// fragHits 每个片段命中的期望片段：ID 相同或正文包含期望的原文
+func fragHits(ranked []fragment, specs []string) ([][]int, []bool) {
+	covered := make([]bool, len(specs))
+	hits := make([][]int, len(ranked))
+	for i, f := range ranked {
+		for j, spec := range specs {
+			if spec == f.id || strings.Contains(f.content, spec) {
+				hits[i] = append(hits[i], j)
+				covered[j] = true
+			}
+		}
+	}
+	return hits, covered
+}
+
+type score struct {
+	recall    map[int]float64
+	rr        float64
+	ndcg      float64
+	firstRank int
+}
+
+// scoreHits 按名次计算指标：某名次命中了此前未命中的期望项即记为相关（二元相关度），
+// recall@k 为前 k 名覆盖的期望项比例，nDCG 按最大的 k 计算
+func scoreHits(hits [][]int, expected int, ks []int) score {
+	maxK := ks[len(ks)-1]
+	s := score{recall: make(map[int]float64, len(ks))}
+	covered := make(map[int]bool)
+	dcg := 0.0
+	for rank := 0; rank < len(hits) && rank < maxK; rank++ {
+		fresh := false
+		for _, j := range hits[rank] {
+			if !covered[j] {
+				covered[j] = true
+				fresh = true
+			}
+		}
+		if fresh {
+			dcg += 1 / math.Log2(float64(rank+2))
+			if s.firstRank == 0 {
+				s.firstRank = rank + 1
+				s.rr = 1 / float64(rank+1)
+			}
+		}
+		for _, k := range ks {
+			if rank+1 == k {
+				s.recall[k] = float64(len(covered)) / float64(expected)
+			}
+		}
+	}
+	// 结果少于 k 条时沿用最后的覆盖率
+	for _, k := range ks {
+		if _, ok := s.recall[k]; !ok {
+			s.recall[k] = float64(len(covered)) / float64(expected)
+		}
+	}
+	idcg := 0.0
+	for rank := 0; rank < expected && rank < maxK; rank++ {
+		idcg += 1 / math.Log2(float64(rank+2))
+	}
+	if idcg > 0 {
+		s.ndcg = dcg / idcg
+	}
+	return s
+}
+
+func average(scores []score, ks []int) *Metrics {
+	if len(scores) == 0 {
+		return nil
+	}
+	m := &Metrics{Questions: len(scores), Recall: make(map[string]float64, len(ks))}
+	for _, s := range scores {
+		for _, k := range ks {
+			m.Recall[strconv.Itoa(k)] += s.recall[k]
+		}
+		m.MRR += s.rr
+		m.NDCG += s.ndcg
+	}
+	n := float64(len(scores))
+	for k := range m.Recall {
+		m.Recall[k] /= n
+	}
+	m.MRR /= n
+	m.NDCG /= n
+	return m
+}
+
+type level struct {
+	name string
+	m    *Metrics
+}
+
+// levels 报告中有结果的粒度，依次为笔记、片段
+func levels(r *Report) []level {
+	out := make([]level, 0, 2)
+	if r.Note != nil {
+		out = append(out, level{"笔记", r.Note})
+	}
+	if r.Fragment != nil {
+		out = append(out, level{"片段", r.Fragment})
+	}
+	return out
+}
+
+func printReport(r *Report, verbose bool) {
+	fmt.Printf("笔记 %d 条，片段 %d 个，问题 %d 个，向量化：%s，相似度阈值 %g\n\n", r.Notes, r.Fragments, len(r.Results), r.Embedding, r.Threshold)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "粒度\t问题数\t"
+	for _, k := range r.K {
+		header += fmt.Sprintf("recall@%d\t", k)
+	}
+	header += fmt.Sprintf("MRR\tnDCG@%d\t", r.K[len(r.K)-1])
+	fmt.Fprintln(w, header)
+	for _, row := range levels(r) {
+		line := fmt.Sprintf("%s\t%d\t", row.name, row.m.Questions)
+		for _, k := range r.K {
+			line += fmt.Sprintf("%.3f\t", row.m.Recall[strconv.Itoa(k)])
+		}
+		line += fmt.Sprintf("%.3f\t%.3f\t", row.m.MRR, row.m.NDCG)
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	if !verbose {
+		return
+	}
+	fmt.Println()
+	for _, res := range r.Results {
+		status := "ok"
+		if len(res.Missed) > 0 {
+			status = "未命中 " + strings.Join(res.Missed, ", ")
+		}
+		fmt.Printf("%-16s 笔记名次 %-2d 片段名次 %-2d 检索到 %s  %s\n", res.ID, res.NoteRank, res.FragRank, strings.Join(res.Retrieved, " "), status)
+	}
+}
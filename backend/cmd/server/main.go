@@ -1,87 +1,78 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"note-system/config"
+	"note-system/internal/es"
 	"note-system/internal/handler"
-	"note-system/internal/model"
+	"note-system/internal/llm"
+	"note-system/internal/logging"
+	"note-system/internal/metrics"
 	"note-system/internal/repository"
 	"note-system/internal/service"
+	"note-system/internal/storage"
+	"note-system/internal/worker"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/goccy/go-yaml"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 )
 
-func loadConfig() (*config.Config, error) {
-	paths := []string{"config/config.local.yaml", "config/config.yaml"}
-	var data []byte
-	var err error
-	for _, p := range paths {
-		b, e := os.ReadFile(p)
-		if e == nil && len(b) > 0 {
-			data = b
-			break
-		}
-		if err == nil {
-			err = e
+func newBlobStore(cfg config.AttachmentConfig) (storage.BlobStore, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return storage.NewLocalStore(cfg.LocalDir)
+	case "s3":
+		return storage.NewS3Store(storage.S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	}
+	return nil, fmt.Errorf("未知的附件存储类型: %s", cfg.Driver)
+}
+
+// watchReload 收到 SIGHUP 时重新加载配置，rag、llm 与 dedup 段立即生效
+func watchReload(h *config.Holder) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			restart, err := h.Reload()
+			if err != nil {
+				slog.Error("重新加载配置失败，继续使用旧配置", "err", err)
+				continue
+			}
+			slog.Info("配置已重新加载（rag/llm/dedup 已生效）")
+			if restart {
+				slog.Warn("检测到 rag/llm/dedup 以外的配置变更，需重启服务后生效")
+			}
 		}
-	}
-	if err != nil {
-		return nil, err
-	}
-	var cfg config.Config
-	err = yaml.Unmarshal(data, &cfg)
-	if err != nil {
-		return nil, err
-	}
-	return &cfg, nil
+	}()
 }
 
 func main() {
 
-	//加载配置文件
-	cfg, err := loadConfig()
+	//加载配置文件（默认值 -> YAML -> 环境变量，校验失败直接退出）
+	cfg, err := config.Load()
 	if err != nil {
-		panic("加载配置失败" + err.Error())
+		panic("加载配置失败：" + err.Error())
 	}
+	logging.Setup(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	cfgHolder := config.NewHolder(cfg)
+	watchReload(cfgHolder)
 
-	if cfg.LLM.URL != "" {
-		_ = os.Setenv("LLM_URL", cfg.LLM.URL)
-	}
-	if cfg.LLM.Model != "" {
-		_ = os.Setenv("LLM_MODEL", cfg.LLM.Model)
-	}
-	if cfg.LLM.MaxTokens > 0 {
-		_ = os.Setenv("LLM_MAX_TOKENS", fmt.Sprintf("%d", cfg.LLM.MaxTokens))
-	}
-	if cfg.Rag.PineconeHost != "" {
-		_ = os.Setenv("PINECONE_HOST", cfg.Rag.PineconeHost)
-	}
-	if cfg.Rag.PineconeAPIKey != "" {
-		_ = os.Setenv("PINECONE_API_KEY", cfg.Rag.PineconeAPIKey)
-	}
-	if cfg.Rag.PineconeIndex != "" {
-		_ = os.Setenv("PINECONE_INDEX", cfg.Rag.PineconeIndex)
-	}
-	if cfg.Rag.EmbeddingURL != "" {
-		_ = os.Setenv("EMBEDDING_URL", cfg.Rag.EmbeddingURL)
-	}
-	if cfg.Rag.EmbedDim > 0 {
-		_ = os.Setenv("EMBED_DIM", fmt.Sprintf("%d", cfg.Rag.EmbedDim))
-	}
-	if cfg.Rag.TopK > 0 {
-		_ = os.Setenv("RAG_TOPK", fmt.Sprintf("%d", cfg.Rag.TopK))
-	}
-	if cfg.Rag.SimilarityThreshold > 0 {
-		_ = os.Setenv("SIMILARITY_THRESHOLD", fmt.Sprintf("%g", cfg.Rag.SimilarityThreshold))
-	}
-
-	db, err := gorm.Open(mysql.Open(cfg.Mysql.Dsn), &gorm.Config{})
+	db, err := repository.OpenDB(cfg.Database.Driver, cfg.Database.DSN(cfg.Mysql))
 	if err != nil {
 		panic("数据库连接失败" + err.Error())
 	}
@@ -94,29 +85,55 @@ func main() {
 	if err != nil {
 		panic("数据库ping失败")
 	}
-	println("数据库连接成功！")
+	slog.Info("数据库连接成功", "driver", cfg.Database.Driver)
 
-	err = db.AutoMigrate(&model.Note{}, &model.Fragment{}, &model.QARecord{})
+	// 执行未执行的数据库迁移，也可通过 go run ./cmd/migrate 单独管理
+	ran, err := repository.Migrate(db)
+	for _, m := range ran {
+		slog.Info("已执行迁移", "version", m.Version, "name", m.Name)
+	}
 	if err != nil {
-		panic("自动创建失败：" + err.Error())
+		panic("数据库迁移失败：" + err.Error())
 	}
-	println("notes表创建/更新成功")
-
-	// 强制统一为 utf8mb4，避免中文出现问号
-	_ = db.Exec("SET NAMES utf8mb4").Error
-	_ = db.Exec("SET character_set_client = utf8mb4").Error
-	_ = db.Exec("SET character_set_connection = utf8mb4").Error
-	_ = db.Exec("SET character_set_results = utf8mb4").Error
-	_ = db.Exec("SET collation_connection = utf8mb4_unicode_ci").Error
-	_ = db.Exec("ALTER TABLE notes CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci").Error
-	_ = db.Exec("ALTER TABLE fragments CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci").Error
-	_ = db.Exec("ALTER TABLE qa_records CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci").Error
+	slog.Info("数据库结构已是最新版本")
 
 	// 步骤3：初始化各层（依赖注入）
-	noteRepo := repository.NewNoteRepo(db)          // Repository 层
-	noteService := service.NewNoteService(noteRepo) // Service 层
-	ragService := service.NewRAGService(db)
-	nh := handler.NewNoteHandler(noteService, ragService)
+	noteRepo := repository.NewNoteRepo(db) // Repository 层
+	retention := time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+	noteService := service.NewNoteService(noteRepo, retention) // Service 层
+	ragService := service.NewRAGService(db, cfgHolder)
+	metrics.RegisterCounts("note_fragments", "片段数，按来源", "source", ragService.CountFragmentsBySource)
+	esClient := es.New(cfg.ES)
+	linkService := service.NewLinkService(noteRepo, repository.NewLinkRepo(db))
+	blobStore, err := newBlobStore(cfg.Attachment)
+	if err != nil {
+		panic("初始化附件存储失败：" + err.Error())
+	}
+	attachmentService := service.NewAttachmentService(noteRepo, repository.NewAttachmentRepo(db), blobStore, int64(cfg.Attachment.MaxUploadMB)<<20)
+	// 根上下文只在优雅退出超时、需要强制结束时取消，进行中的外部调用随之中断
+	rootCtx, cancelRoot := context.WithCancel(context.Background())
+	defer cancelRoot()
+	workers := worker.NewGroup(rootCtx)
+	workers.Go("summary-backfill", func(ctx context.Context, stop <-chan struct{}) {
+		n, err := noteService.BackfillSummaries(stop)
+		logging.WarnIf(ctx, err, "补齐笔记摘要失败")
+		if n > 0 {
+			slog.Info("已补齐笔记摘要", "count", n)
+		}
+	})
+	if retention > 0 {
+		sweeper := service.NewTrashSweeper(noteService, ragService, linkService, attachmentService, esClient, time.Duration(cfg.Trash.SweepIntervalMinutes)*time.Minute)
+		workers.Go("trash-sweeper", sweeper.Run)
+	}
+	chatClient := llm.NewClient(cfgHolder)
+	summaryService := service.NewSummaryService(noteRepo, ragService, chatClient, cfgHolder)
+	queryExpander := service.NewQueryExpander(chatClient, cfgHolder)
+	relatedService := service.NewRelatedService(noteRepo, ragService, cfgHolder)
+	duplicateService := service.NewDuplicateService(noteRepo, ragService, cfgHolder)
+	workers.Go("dedup-scanner", duplicateService.Run)
+	workers.Go("summarizer", summaryService.Run)
+	nh := handler.NewNoteHandler(rootCtx, noteService, ragService, linkService, attachmentService, summaryService, relatedService, duplicateService, queryExpander, chatClient, esClient, cfgHolder)
+	sh := handler.NewStatusHandler(service.NewStatusService(db, esClient, cfgHolder))
 	// 通过闭包方式注入 RAGService
 	func() { // anonymous init
 		// reflect injection avoided; exported field not settable here
@@ -124,13 +141,15 @@ func main() {
 	}()
 
 	// 步骤4：创建 Gin 引擎，注册路由
-	r := gin.Default() // 默认开启日志和恢复中间件
+	r := gin.New()
+	// 恢复中间件 + 结构化访问日志（含请求ID）+ 指标 + 统一错误响应
+	r.Use(gin.Recovery(), logging.Middleware(), metrics.Middleware(), handler.ErrorMiddleware())
 	// 新增：添加跨域中间件
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:8080", "http://localhost:5173", "http://localhost:5174"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"}, // 允许的请求方法
-		AllowHeaders:     []string{"Content-Type"},                 // 允许的请求头
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},                             // 允许的请求方法
+		AllowHeaders:     []string{"Content-Type", "Accept-Language", logging.HeaderRequestID}, // 允许的请求头
+		ExposeHeaders:    []string{"Content-Length", logging.HeaderRequestID},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -142,28 +161,90 @@ func main() {
 		api.GET("/search", nh.SearchNotes)
 		api.GET("/list", nh.ListNotes)
 		api.POST("/seed-cn", nh.SeedCNNotes)
+		api.POST("/batch", nh.BatchNotes)
 		api.GET("/trash", nh.ListDeleted)
+		api.GET("/duplicates", nh.Duplicates)
+		api.POST("/duplicates/merge", nh.MergeNotes)
 		api.PUT("/:id/restore", nh.Restore)
 		api.DELETE("/:id/hard", nh.HardDelete)
 		api.GET("/:id", nh.GetNoteByID)
+		api.GET("/:id/backlinks", nh.Backlinks)
+		api.GET("/:id/outlinks", nh.Outlinks)
+		api.GET("/:id/related", nh.RelatedNotes)
+		api.GET("/:id/merges", nh.Merges)
+		api.POST("/:id/summarize", nh.Summarize)
+		api.POST("/:id/attachments", nh.UploadAttachment)
+		api.GET("/:id/attachments", nh.ListAttachments)
 		api.PUT("/:id", nh.UpdateNote)
 		api.DELETE("/:id", nh.DeleteNote)
 		api.DELETE("/purge", nh.PurgeAll)
+		api.POST("/reindex", nh.ReindexVectors)
 	}
 
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/healthz", sh.Healthz)
+	r.GET("/readyz", sh.Readyz)
+	r.GET("/api/status", sh.Status)
+	r.GET("/api/graph", nh.Graph)
+	r.POST("/api/clip", nh.ClipPage)
+	r.GET("/api/attachment/:id", nh.DownloadAttachment)
+	r.DELETE("/api/attachment/:id", nh.DeleteAttachment)
+
 	rag := r.Group("/api/rag")
 	{
 		rag.GET("/search", nh.RagSearch)
 		rag.POST("/qa", nh.RagQA)
 	}
 
-	// OpenAI 风格的本地模拟端点
+	// 本地模拟端点：OpenAI 风格与 Ollama 风格
 	r.POST("/v1/chat/completions", nh.MockLLM)
+	r.POST("/api/chat", nh.MockLLM)
 
 	// 步骤5：启动 HTTP 服务
-	fmt.Println("服务启动成功,访问地址:http://127.0.0.1:" + cfg.Server.Port)
-	err = r.Run(":" + cfg.Server.Port)
-	if err != nil {
-		panic(fmt.Sprintf("服务启动失败：%v", err))
+	srv := &http.Server{
+		Addr:        ":" + cfg.Server.Port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return rootCtx },
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("服务启动成功", "addr", "http://127.0.0.1:"+cfg.Server.Port)
+
+	// 步骤6：等待退出信号，先停止接收新请求并等待进行中的请求，再停止后台任务
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			panic(fmt.Sprintf("服务启动失败：%v", err))
+		}
+	case sig := <-quit:
+		slog.Info("开始优雅退出", "signal", sig.String())
+	}
+	signal.Stop(quit)
+	shutdown(srv, workers, cancelRoot, time.Duration(cfg.Server.ShutdownTimeoutSeconds)*time.Second)
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+	slog.Info("服务已退出")
+}
+
+// shutdown 在 timeout 内等待进行中的请求与后台任务完成；超时后取消根上下文强制中断外部调用
+func shutdown(srv *http.Server, workers *worker.Group, cancelRoot context.CancelFunc, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("等待进行中的请求超时，强制中断", "err", err)
+		cancelRoot()
+	}
+	if err := workers.Shutdown(ctx); err != nil {
+		slog.Warn("等待后台任务超时，强制中断", "err", err)
+		cancelRoot()
+		// 给被中断的任务一点时间退出到检查点
+		grace, cancelGrace := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancelGrace()
+		_ = workers.Shutdown(grace)
 	}
 }
@@ -1,32 +1,158 @@
 package config
 
+// Config 服务的全部配置。加载顺序：内置默认值 -> YAML 文件 -> 环境变量覆盖（见 env.go），最后统一校验
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Mysql  MysqlConfig  `yaml:"mysql"`
-	Rag    RagConfig    `yaml:"rag"`
-	LLM    LLMConfig    `yaml:"llm"`
+	Server     ServerConfig     `yaml:"server"`
+	Log        LogConfig        `yaml:"log"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Mysql      MysqlConfig      `yaml:"mysql"`
+	ES         ESConfig         `yaml:"es"`
+	Rag        RagConfig        `yaml:"rag"`
+	LLM        LLMConfig        `yaml:"llm"`
+	Trash      TrashConfig      `yaml:"trash"`
+	Attachment AttachmentConfig `yaml:"attachment"`
+	Dedup      DedupConfig      `yaml:"dedup"`
 }
 
 type ServerConfig struct {
 	Port string `yaml:"port"`
+	// ShutdownTimeoutSeconds 收到退出信号后等待进行中请求与后台任务完成的最长时间
+	ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds"`
+}
+
+// LogConfig 日志输出
+type LogConfig struct {
+	// Level debug/info/warn/error
+	Level string `yaml:"level"`
+	// Format text（便于本地阅读）或 json（便于日志系统采集）
+	Format string `yaml:"format"`
+}
+
+// DatabaseConfig 数据库驱动选择
+type DatabaseConfig struct {
+	// Driver mysql（默认）或 sqlite；sqlite 使用单个文件，适合个人使用与集成测试
+	Driver string `yaml:"driver"`
+	// SQLitePath sqlite 数据库文件路径
+	SQLitePath string `yaml:"sqlite_path"`
+}
+
+// DSN 返回当前驱动使用的连接串
+func (d DatabaseConfig) DSN(mysql MysqlConfig) string {
+	if d.Driver == "sqlite" {
+		return d.SQLitePath
+	}
+	return mysql.Dsn
 }
 
 type MysqlConfig struct {
 	Dsn string `yaml:"dsn"`
 }
 
+// ESConfig ElasticSearch 全文检索
+type ESConfig struct {
+	URL   string `yaml:"url"`
+	Index string `yaml:"index"`
+	// TimeoutSeconds 单次请求超时
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// RagConfig 向量检索配置，支持 SIGHUP 热加载
 type RagConfig struct {
-	PineconeHost        string  `yaml:"pinecone_host"`
-	PineconeAPIKey      string  `yaml:"pinecone_api_key"`
-	PineconeIndex       string  `yaml:"pinecone_index"`
+	PineconeHost   string `yaml:"pinecone_host"`
+	PineconeAPIKey string `yaml:"pinecone_api_key"`
+	PineconeIndex  string `yaml:"pinecone_index"`
+	// EmbeddingURL 为空时使用本地哈希向量
 	EmbeddingURL        string  `yaml:"embedding_url"`
 	EmbedDim            int     `yaml:"embed_dim"`
 	TopK                int     `yaml:"topk"`
-	SimilarityThreshold float64 `yaml:"similarity_threshold"`
+	SimilarityThreshold float64 `yaml:"similarity_threshold"` // 检索与问答的最低相似度，问答时没有片段达到该值则不调用 LLM
+	// 单次请求超时（秒）
+	PineconeTimeoutSeconds  int `yaml:"pinecone_timeout_seconds"`
+	EmbeddingTimeoutSeconds int `yaml:"embedding_timeout_seconds"`
+	// QueryRewrite 问答检索前调用 LLM 结合对话历史把问题改写为独立问题，并生成多个问法一起检索
+	QueryRewrite bool `yaml:"query_rewrite"`
+	// QueryVariants 开启改写时额外生成的问法数量
+	QueryVariants int `yaml:"query_variants"`
+	// HyDE 开启改写时再让 LLM 写一段假设性回答参与检索
+	HyDE bool `yaml:"hyde"`
+	// GroundingCheck 问答生成后逐句核对回答能否在引用的片段中找到依据，标出无依据的句子
+	GroundingCheck bool `yaml:"grounding_check"`
+	// GroundingMinSupport 句子词元在片段中出现的比例低于该值时视为无依据
+	GroundingMinSupport float64 `yaml:"grounding_min_support"`
 }
 
+// LLMConfig 对话模型接口，支持 SIGHUP 热加载
 type LLMConfig struct {
-	URL       string `yaml:"url"`
-	Model     string `yaml:"model"`
-	MaxTokens int    `yaml:"max_tokens"`
+	// Provider 接口协议：openai（OpenAI 兼容的 chat/completions，默认）或 ollama（/api/chat）
+	Provider string `yaml:"provider"`
+	// URL 为空时问答直接返回检索到的片段
+	URL   string `yaml:"url"`
+	Model string `yaml:"model"`
+	// MaxTokens 单次回答的 token 上限，构造提示词时从上下文窗口中为其预留
+	MaxTokens int `yaml:"max_tokens"`
+	// ContextTokens 模型的上下文窗口大小，问答时检索片段按剩余预算截取
+	ContextTokens int `yaml:"context_tokens"`
+	// TimeoutSeconds 单次请求超时，生成较长回答时需适当调大
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// SummaryEnabled 保存笔记后在后台调用 LLM 生成摘要、建议标题与标签；手动触发不受此开关限制
+	SummaryEnabled bool `yaml:"summary_enabled"`
+	// PromptDir 提示词模板目录，其中与内置模板同名的文件（qa.tmpl、summary.tmpl 等）覆盖内置模板，每次调用时读取
+	PromptDir string `yaml:"prompt_dir"`
+}
+
+// TrashConfig 回收站保留策略
+type TrashConfig struct {
+	// RetentionDays 回收站笔记保留天数，<=0 表示永久保留
+	RetentionDays int `yaml:"retention_days"`
+	// SweepIntervalMinutes 后台清理的执行间隔（分钟）
+	SweepIntervalMinutes int `yaml:"sweep_interval_minutes"`
+}
+
+// DedupConfig 重复笔记检测，支持 SIGHUP 热加载
+type DedupConfig struct {
+	// ScanIntervalMinutes 后台扫描间隔（分钟），0 表示只在查询重复笔记时按需扫描
+	ScanIntervalMinutes int `yaml:"scan_interval_minutes"`
+	// MinHashThreshold 两条笔记词元集合的 Jaccard 相似度（MinHash 估计）达到该值视为近似重复
+	MinHashThreshold float64 `yaml:"minhash_threshold"`
+	// VectorThreshold 两条笔记片段向量质心的余弦相似度达到该值视为近似重复，需配置向量库
+	VectorThreshold float64 `yaml:"vector_threshold"`
+}
+
+// AttachmentConfig 附件存储配置
+type AttachmentConfig struct {
+	// Driver 存储后端：local（默认）或 s3
+	Driver string `yaml:"driver"`
+	// LocalDir 本地存储目录
+	LocalDir string `yaml:"local_dir"`
+	// MaxUploadMB 单个附件大小上限（MB）
+	MaxUploadMB int    `yaml:"max_upload_mb"`
+	S3Endpoint  string `yaml:"s3_endpoint"`
+	S3Bucket    string `yaml:"s3_bucket"`
+	S3Region    string `yaml:"s3_region"`
+	S3AccessKey string `yaml:"s3_access_key"`
+	S3SecretKey string `yaml:"s3_secret_key"`
+}
+
+// Default 内置默认值，YAML 与环境变量中未出现的字段保持这些值
+func Default() Config {
+	return Config{
+		Server:   ServerConfig{Port: "8090", ShutdownTimeoutSeconds: 30},
+		Log:      LogConfig{Level: "info", Format: "text"},
+		Database: DatabaseConfig{Driver: "mysql", SQLitePath: "data/notes.db"},
+		ES:       ESConfig{URL: "http://localhost:9200", Index: "notes", TimeoutSeconds: 10},
+		Rag: RagConfig{
+			PineconeIndex:           "notes-index",
+			EmbedDim:                1024,
+			TopK:                    5,
+			SimilarityThreshold:     0.7,
+			PineconeTimeoutSeconds:  10,
+			EmbeddingTimeoutSeconds: 30,
+			QueryVariants:           2,
+			GroundingMinSupport:     0.5,
+		},
+		LLM:        LLMConfig{Provider: "openai", Model: "phi-4", MaxTokens: 2048, ContextTokens: 16384, TimeoutSeconds: 60},
+		Trash:      TrashConfig{RetentionDays: 30, SweepIntervalMinutes: 60},
+		Attachment: AttachmentConfig{Driver: "local", LocalDir: "data/blobs", MaxUploadMB: 20},
+		Dedup:      DedupConfig{MinHashThreshold: 0.8, VectorThreshold: 0.95},
+	}
 }
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// 环境变量覆盖，优先级高于 YAML：
+//
+//	SERVER_PORT            server.port
+//	SHUTDOWN_TIMEOUT       server.shutdown_timeout_seconds
+//	LOG_LEVEL              log.level
+//	LOG_FORMAT             log.format
+//	DB_DRIVER              database.driver
+//	SQLITE_PATH            database.sqlite_path
+//	MYSQL_DSN              mysql.dsn
+//	ES_URL                 es.url
+//	ES_INDEX               es.index
+//	PINECONE_HOST          rag.pinecone_host
+//	PINECONE_API_KEY       rag.pinecone_api_key
+//	PINECONE_INDEX         rag.pinecone_index
+//	EMBEDDING_URL          rag.embedding_url
+//	EMBED_DIM              rag.embed_dim
+//	RAG_TOPK               rag.topk
+//	SIMILARITY_THRESHOLD   rag.similarity_threshold
+//	RAG_QUERY_REWRITE      rag.query_rewrite
+//	RAG_HYDE               rag.hyde
+//	RAG_GROUNDING_CHECK    rag.grounding_check
+//	LLM_PROVIDER           llm.provider
+//	LLM_URL                llm.url
+//	LLM_MODEL              llm.model
+//	LLM_MAX_TOKENS         llm.max_tokens
+//	LLM_CONTEXT_TOKENS     llm.context_tokens
+//	LLM_SUMMARY            llm.summary_enabled
+//	LLM_PROMPT_DIR         llm.prompt_dir
+//	TRASH_RETENTION_DAYS   trash.retention_days
+//	ATTACHMENT_DRIVER      attachment.driver
+//	ATTACHMENT_LOCAL_DIR   attachment.local_dir
+//	ATTACHMENT_MAX_MB      attachment.max_upload_mb
+//	S3_ENDPOINT            attachment.s3_endpoint
+//	S3_BUCKET              attachment.s3_bucket
+//	S3_REGION              attachment.s3_region
+//	S3_ACCESS_KEY          attachment.s3_access_key
+//	S3_SECRET_KEY          attachment.s3_secret_key
+//	DEDUP_SCAN_INTERVAL    dedup.scan_interval_minutes
+//	DEDUP_MINHASH          dedup.minhash_threshold
+//	DEDUP_VECTOR           dedup.vector_threshold
+var envBindings = []struct {
+	key   string
+	apply func(c *Config, v string) error
+}{
+	{"SERVER_PORT", str(func(c *Config) *string { return &c.Server.Port })},
+	{"SHUTDOWN_TIMEOUT", integer(func(c *Config) *int { return &c.Server.ShutdownTimeoutSeconds })},
+	{"LOG_LEVEL", str(func(c *Config) *string { return &c.Log.Level })},
+	{"LOG_FORMAT", str(func(c *Config) *string { return &c.Log.Format })},
+	{"DB_DRIVER", str(func(c *Config) *string { return &c.Database.Driver })},
+	{"SQLITE_PATH", str(func(c *Config) *string { return &c.Database.SQLitePath })},
+	{"MYSQL_DSN", str(func(c *Config) *string { return &c.Mysql.Dsn })},
+	{"ES_URL", str(func(c *Config) *string { return &c.ES.URL })},
+	{"ES_INDEX", str(func(c *Config) *string { return &c.ES.Index })},
+	{"PINECONE_HOST", str(func(c *Config) *string { return &c.Rag.PineconeHost })},
+	{"PINECONE_API_KEY", str(func(c *Config) *string { return &c.Rag.PineconeAPIKey })},
+	{"PINECONE_INDEX", str(func(c *Config) *string { return &c.Rag.PineconeIndex })},
+	{"EMBEDDING_URL", str(func(c *Config) *string { return &c.Rag.EmbeddingURL })},
+	{"EMBED_DIM", integer(func(c *Config) *int { return &c.Rag.EmbedDim })},
+	{"RAG_TOPK", integer(func(c *Config) *int { return &c.Rag.TopK })},
+	{"SIMILARITY_THRESHOLD", float(func(c *Config) *float64 { return &c.Rag.SimilarityThreshold })},
+	{"RAG_QUERY_REWRITE", boolean(func(c *Config) *bool { return &c.Rag.QueryRewrite })},
+	{"RAG_HYDE", boolean(func(c *Config) *bool { return &c.Rag.HyDE })},
+	{"RAG_GROUNDING_CHECK", boolean(func(c *Config) *bool { return &c.Rag.GroundingCheck })},
+	{"LLM_PROVIDER", str(func(c *Config) *string { return &c.LLM.Provider })},
+	{"LLM_URL", str(func(c *Config) *string { return &c.LLM.URL })},
+	{"LLM_MODEL", str(func(c *Config) *string { return &c.LLM.Model })},
+	{"LLM_MAX_TOKENS", integer(func(c *Config) *int { return &c.LLM.MaxTokens })},
+	{"LLM_CONTEXT_TOKENS", integer(func(c *Config) *int { return &c.LLM.ContextTokens })},
+	{"LLM_SUMMARY", boolean(func(c *Config) *bool { return &c.LLM.SummaryEnabled })},
+	{"LLM_PROMPT_DIR", str(func(c *Config) *string { return &c.LLM.PromptDir })},
+	{"TRASH_RETENTION_DAYS", integer(func(c *Config) *int { return &c.Trash.RetentionDays })},
+	{"ATTACHMENT_DRIVER", str(func(c *Config) *string { return &c.Attachment.Driver })},
+	{"ATTACHMENT_LOCAL_DIR", str(func(c *Config) *string { return &c.Attachment.LocalDir })},
+	{"ATTACHMENT_MAX_MB", integer(func(c *Config) *int { return &c.Attachment.MaxUploadMB })},
+	{"S3_ENDPOINT", str(func(c *Config) *string { return &c.Attachment.S3Endpoint })},
+	{"S3_BUCKET", str(func(c *Config) *string { return &c.Attachment.S3Bucket })},
+	{"S3_REGION", str(func(c *Config) *string { return &c.Attachment.S3Region })},
+	{"S3_ACCESS_KEY", str(func(c *Config) *string { return &c.Attachment.S3AccessKey })},
+	{"S3_SECRET_KEY", str(func(c *Config) *string { return &c.Attachment.S3SecretKey })},
+	{"DEDUP_SCAN_INTERVAL", integer(func(c *Config) *int { return &c.Dedup.ScanIntervalMinutes })},
+	{"DEDUP_MINHASH", float(func(c *Config) *float64 { return &c.Dedup.MinHashThreshold })},
+	{"DEDUP_VECTOR", float(func(c *Config) *float64 { return &c.Dedup.VectorThreshold })},
+}
+
+// applyEnv 用已设置的环境变量覆盖配置
+func applyEnv(c *Config) error {
+	for _, b := range envBindings {
+		v, ok := os.LookupEnv(b.key)
+		if !ok {
+			continue
+		}
+		if err := b.apply(c, v); err != nil {
+			return fmt.Errorf("环境变量 %s=%q 无效: %w", b.key, v, err)
+		}
+	}
+	return nil
+}
+
+func str(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func integer(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolean(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func float(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}
+}
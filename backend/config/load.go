@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultPaths 未指定配置文件时依次尝试的路径，本地覆盖文件优先
+var DefaultPaths = []string{"config/config.local.yaml", "config/config.yaml"}
+
+// Load 按 默认值 -> 第一个存在的 YAML 文件 -> 环境变量 的顺序加载并校验配置
+func Load(paths ...string) (*Config, error) {
+	if len(paths) == 0 {
+		paths = DefaultPaths
+	}
+	cfg := Default()
+	var data []byte
+	var err error
+	for _, p := range paths {
+		b, e := os.ReadFile(p)
+		if e == nil && len(b) > 0 {
+			data, err = b, nil
+			break
+		}
+		if err == nil {
+			err = e
+		}
+	}
+	if data == nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置校验失败:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// Holder 持有当前生效的配置，供各层在每次调用时读取，使热加载无需重建对象
+type Holder struct {
+	paths []string
+	cur   atomic.Pointer[Config]
+}
+
+func NewHolder(cfg *Config, paths ...string) *Holder {
+	h := &Holder{paths: paths}
+	h.cur.Store(cfg)
+	return h
+}
+
+// Get 返回当前配置，调用方不得修改
+func (h *Holder) Get() *Config {
+	return h.cur.Load()
+}
+
+// Reload 重新加载配置文件与环境变量，仅 rag、llm 与 dedup 段立即生效；
+// 其他段的变更需要重启，返回的 restartNeeded 为 true。校验失败时保留旧配置
+func (h *Holder) Reload() (restartNeeded bool, err error) {
+	next, err := Load(h.paths...)
+	if err != nil {
+		return false, err
+	}
+	old := h.Get()
+	if old == nil {
+		return false, errors.New("配置尚未初始化")
+	}
+	merged := *old
+	merged.Rag = next.Rag
+	merged.LLM = next.LLM
+	merged.Dedup = next.Dedup
+	h.cur.Store(&merged)
+
+	next.Rag, next.LLM, next.Dedup = old.Rag, old.LLM, old.Dedup
+	return *next != *old, nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Validate 校验配置，返回全部不合法字段
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if p, err := strconv.Atoi(c.Server.Port); err != nil || p <= 0 || p > 65535 {
+		fail("server.port", "端口不合法 %q", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeoutSeconds <= 0 {
+		fail("server.shutdown_timeout_seconds", "必须大于0")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log.level", "只支持 debug/info/warn/error，当前为 %q", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		fail("log.format", "只支持 text 或 json，当前为 %q", c.Log.Format)
+	}
+	switch c.Database.Driver {
+	case "mysql":
+		if c.Mysql.Dsn == "" {
+			fail("mysql.dsn", "不能为空")
+		} else if _, err := mysql.ParseDSN(c.Mysql.Dsn); err != nil {
+			fail("mysql.dsn", "格式错误: %v", err)
+		}
+	case "sqlite":
+		if c.Database.SQLitePath == "" {
+			fail("database.sqlite_path", "不能为空")
+		}
+	default:
+		fail("database.driver", "只支持 mysql 或 sqlite，当前为 %q", c.Database.Driver)
+	}
+	if !validURL(c.ES.URL, false) {
+		fail("es.url", "地址不合法 %q", c.ES.URL)
+	}
+	if c.ES.Index == "" {
+		fail("es.index", "不能为空")
+	}
+	if c.ES.TimeoutSeconds <= 0 {
+		fail("es.timeout_seconds", "必须大于0")
+	}
+	errs = append(errs, c.Rag.validate()...)
+	errs = append(errs, c.LLM.validate()...)
+	if c.Trash.RetentionDays > 0 && c.Trash.SweepIntervalMinutes <= 0 {
+		fail("trash.sweep_interval_minutes", "必须大于0")
+	}
+	switch c.Attachment.Driver {
+	case "local":
+		if c.Attachment.LocalDir == "" {
+			fail("attachment.local_dir", "不能为空")
+		}
+	case "s3":
+		if !validURL(c.Attachment.S3Endpoint, false) {
+			fail("attachment.s3_endpoint", "地址不合法 %q", c.Attachment.S3Endpoint)
+		}
+		if c.Attachment.S3Bucket == "" {
+			fail("attachment.s3_bucket", "不能为空")
+		}
+	default:
+		fail("attachment.driver", "只支持 local 或 s3，当前为 %q", c.Attachment.Driver)
+	}
+	if c.Attachment.MaxUploadMB <= 0 {
+		fail("attachment.max_upload_mb", "必须大于0")
+	}
+	if c.Dedup.ScanIntervalMinutes < 0 {
+		fail("dedup.scan_interval_minutes", "不能小于0")
+	}
+	if c.Dedup.MinHashThreshold <= 0 || c.Dedup.MinHashThreshold > 1 {
+		fail("dedup.minhash_threshold", "必须在 0~1 之间，当前为 %g", c.Dedup.MinHashThreshold)
+	}
+	if c.Dedup.VectorThreshold <= 0 || c.Dedup.VectorThreshold > 1 {
+		fail("dedup.vector_threshold", "必须在 0~1 之间，当前为 %g", c.Dedup.VectorThreshold)
+	}
+	return errors.Join(errs...)
+}
+
+func (r RagConfig) validate() []error {
+	var errs []error
+	if r.EmbedDim <= 0 {
+		errs = append(errs, fmt.Errorf("rag.embed_dim: 必须大于0，当前为 %d", r.EmbedDim))
+	}
+	if r.TopK <= 0 || r.TopK > 100 {
+		errs = append(errs, fmt.Errorf("rag.topk: 必须在 1~100 之间，当前为 %d", r.TopK))
+	}
+	if r.SimilarityThreshold < 0 || r.SimilarityThreshold > 1 {
+		errs = append(errs, fmt.Errorf("rag.similarity_threshold: 必须在 0~1 之间，当前为 %g", r.SimilarityThreshold))
+	}
+	if r.QueryVariants < 0 || r.QueryVariants > 5 {
+		errs = append(errs, fmt.Errorf("rag.query_variants: 必须在 0~5 之间，当前为 %d", r.QueryVariants))
+	}
+	if r.GroundingMinSupport < 0 || r.GroundingMinSupport > 1 {
+		errs = append(errs, fmt.Errorf("rag.grounding_min_support: 必须在 0~1 之间，当前为 %g", r.GroundingMinSupport))
+	}
+	if !validURL(r.PineconeHost, true) {
+		errs = append(errs, fmt.Errorf("rag.pinecone_host: 地址不合法 %q", r.PineconeHost))
+	}
+	if !validURL(r.EmbeddingURL, true) {
+		errs = append(errs, fmt.Errorf("rag.embedding_url: 地址不合法 %q", r.EmbeddingURL))
+	}
+	if r.PineconeTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("rag.pinecone_timeout_seconds: 必须大于0，当前为 %d", r.PineconeTimeoutSeconds))
+	}
+	if r.EmbeddingTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("rag.embedding_timeout_seconds: 必须大于0，当前为 %d", r.EmbeddingTimeoutSeconds))
+	}
+	return errs
+}
+
+func (l LLMConfig) validate() []error {
+	var errs []error
+	if l.Provider != "openai" && l.Provider != "ollama" {
+		errs = append(errs, fmt.Errorf("llm.provider: 仅支持 openai 或 ollama，当前为 %q", l.Provider))
+	}
+	if !validURL(l.URL, true) {
+		errs = append(errs, fmt.Errorf("llm.url: 地址不合法 %q", l.URL))
+	}
+	if l.MaxTokens <= 0 {
+		errs = append(errs, fmt.Errorf("llm.max_tokens: 必须大于0，当前为 %d", l.MaxTokens))
+	}
+	if l.ContextTokens <= l.MaxTokens {
+		errs = append(errs, fmt.Errorf("llm.context_tokens: 必须大于 max_tokens（%d），当前为 %d", l.MaxTokens, l.ContextTokens))
+	}
+	if l.TimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("llm.timeout_seconds: 必须大于0，当前为 %d", l.TimeoutSeconds))
+	}
+	return errs
+}
+
+// validURL 校验 http(s) 地址，allowEmpty 为 true 时空串视为未启用
+func validURL(s string, allowEmpty bool) bool {
+	if s == "" {
+		return allowEmpty
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
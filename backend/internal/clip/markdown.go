@@ -0,0 +1,302 @@
+package clip
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var blockAtoms = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Pre: true, atom.Blockquote: true, atom.Hr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Table: true,
+	atom.Section: true, atom.Article: true, atom.Main: true, atom.Header: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Details: true, atom.Summary: true,
+}
+
+type converter struct {
+	base *url.URL
+}
+
+// toMarkdown 将节点的子树转换为 Markdown，块之间以空行分隔
+func toMarkdown(n *html.Node, base *url.URL) string {
+	c := &converter{base: base}
+	return strings.Join(c.blocks(n, 0), "\n\n")
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockAtoms[n.DataAtom]
+}
+
+// blocks 转换 n 的子节点；相邻的行内内容合并为一个段落
+func (c *converter) blocks(n *html.Node, depth int) []string {
+	out := make([]string, 0)
+	var inline strings.Builder
+	flush := func() {
+		if t := tidyInline(inline.String()); t != "" {
+			out = append(out, t)
+		}
+		inline.Reset()
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if isBlock(ch) {
+			flush()
+			out = append(out, c.block(ch, depth)...)
+		} else {
+			inline.WriteString(c.inline(ch))
+		}
+	}
+	flush()
+	return out
+}
+
+func (c *converter) block(n *html.Node, depth int) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		t := tidyInline(c.inlineChildren(n))
+		if t == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + strings.ReplaceAll(t, "\n", " ")}
+	case atom.P, atom.Summary, atom.Figcaption:
+		if t := tidyInline(c.inlineChildren(n)); t != "" {
+			return []string{t}
+		}
+		return nil
+	case atom.Pre:
+		code := strings.Trim(textOf(n), "\n")
+		if strings.TrimSpace(code) == "" {
+			return nil
+		}
+		return []string{"```" + codeLang(n) + "\n" + code + "\n```"}
+	case atom.Blockquote:
+		inner := strings.Join(c.blocks(n, depth), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+	case atom.Ul, atom.Ol:
+		if l := c.list(n, depth); l != "" {
+			return []string{l}
+		}
+		return nil
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Table:
+		if t := c.table(n); t != "" {
+			return []string{t}
+		}
+		return nil
+	}
+	return c.blocks(n, depth)
+}
+
+func (c *converter) list(n *html.Node, depth int) string {
+	ordered := n.DataAtom == atom.Ol
+	indent := strings.Repeat("  ", depth)
+	lines := make([]string, 0)
+	idx := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		var text strings.Builder
+		nested := make([]string, 0)
+		for ch := li.FirstChild; ch != nil; ch = ch.NextSibling {
+			switch {
+			case ch.Type == html.ElementNode && (ch.DataAtom == atom.Ul || ch.DataAtom == atom.Ol):
+				if l := c.list(ch, depth+1); l != "" {
+					nested = append(nested, l)
+				}
+			case isBlock(ch):
+				text.WriteString(" " + strings.Join(c.block(ch, depth+1), " "))
+			default:
+				text.WriteString(c.inline(ch))
+			}
+		}
+		item := strings.ReplaceAll(tidyInline(text.String()), "\n", " ")
+		if item == "" && len(nested) == 0 {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", idx)
+			idx++
+		}
+		lines = append(lines, indent+marker+item)
+		lines = append(lines, nested...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *converter) table(n *html.Node) string {
+	rows := make([][]string, 0)
+	walk(n, func(tr *html.Node) {
+		if tr.Type != html.ElementNode || tr.DataAtom != atom.Tr {
+			return
+		}
+		cells := make([]string, 0)
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type == html.ElementNode && (td.DataAtom == atom.Td || td.DataAtom == atom.Th) {
+				cell := strings.ReplaceAll(tidyInline(c.inlineChildren(td)), "\n", " ")
+				cells = append(cells, strings.ReplaceAll(cell, "|", `\|`))
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	})
+	if len(rows) == 0 {
+		return ""
+	}
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, r := range rows {
+		for len(r) < cols {
+			r = append(r, "")
+		}
+		lines = append(lines, "| "+strings.Join(r, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *converter) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.inline(ch))
+	}
+	return sb.String()
+}
+
+func (c *converter) inline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return squash(n.Data)
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	switch n.DataAtom {
+	case atom.A:
+		text := strings.TrimSpace(c.inlineChildren(n))
+		href := c.resolve(attr(n, "href"))
+		if text == "" || href == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Strong, atom.B:
+		return wrap(c.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.inlineChildren(n), "*")
+	case atom.Del, atom.S:
+		return wrap(c.inlineChildren(n), "~~")
+	case atom.Code, atom.Kbd:
+		if t := textOf(n); strings.TrimSpace(t) != "" {
+			return "`" + strings.TrimSpace(t) + "`"
+		}
+		return ""
+	case atom.Img:
+		src := attr(n, "src")
+		if src == "" {
+			src = attr(n, "data-src")
+		}
+		if src = c.resolve(src); src == "" {
+			return ""
+		}
+		return "![" + strings.TrimSpace(attr(n, "alt")) + "](" + src + ")"
+	case atom.Br:
+		return "\n"
+	}
+	return c.inlineChildren(n)
+}
+
+// resolve 以来源地址补全相对链接，丢弃 javascript:、data: 等链接
+func (c *converter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return u.String()
+	case "":
+		if c.base == nil {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+func codeLang(pre *html.Node) string {
+	for _, n := range []*html.Node{pre, findFirst(pre, atom.Code)} {
+		if n == nil {
+			continue
+		}
+		for _, cls := range strings.Fields(attr(n, "class")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if strings.HasPrefix(cls, prefix) {
+					return strings.TrimPrefix(cls, prefix)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func wrap(s, mark string) string {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return ""
+	}
+	return mark + t + mark
+}
+
+// squash 连续空白合并为一个空格
+func squash(s string) string {
+	t := strings.Join(strings.Fields(s), " ")
+	if t == "" {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	if s[0] == ' ' || s[0] == '\n' || s[0] == '\t' || s[0] == '\r' {
+		t = " " + t
+	}
+	if last := s[len(s)-1]; last == ' ' || last == '\n' || last == '\t' || last == '\r' {
+		t += " "
+	}
+	return t
+}
+
+// tidyInline 去掉每行首尾空白与空行
+func tidyInline(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, l := range lines {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,244 @@
+package clip
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoContent 页面中没有找到可用的正文
+var ErrNoContent = errors.New("未能从页面中提取正文")
+
+// Article 剪藏结果
+type Article struct {
+	Title    string
+	SiteName string
+	Markdown string
+}
+
+var (
+	// 明显不是正文的 class/id
+	unlikelyRe = regexp.MustCompile(`(?i)comment|sidebar|footer|\bnav|menu|share|social|related|advert|\bads?\b|promo|cookie|banner|popup|subscribe|breadcrumb|pagination|disqus|sponsor`)
+	// 倾向于正文的 class/id
+	positiveRe = regexp.MustCompile(`(?i)article|content|post|entry|main|body|text|story|blog`)
+)
+
+// 整棵子树不参与正文抽取的标签
+var dropTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Svg: true, atom.Canvas: true, atom.Template: true,
+}
+
+// Extract 以 readability 风格的启发式规则找出正文并转换为 Markdown，sourceURL 用于补全相对链接
+func Extract(page []byte, sourceURL string) (*Article, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(sourceURL)
+	art := &Article{Title: findTitle(doc), SiteName: metaContent(doc, "og:site_name")}
+
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		return nil, ErrNoContent
+	}
+	prune(body)
+	top := pickContent(body)
+	if top == nil {
+		top = body
+	}
+	md := strings.TrimSpace(toMarkdown(top, base))
+	if md == "" {
+		return nil, ErrNoContent
+	}
+	// <title> 常带有站点后缀，正文一级标题包含在其中时优先用一级标题
+	if h := findFirst(top, atom.H1); h != nil {
+		if h1 := strings.TrimSpace(textOf(h)); h1 != "" && (art.Title == "" || strings.Contains(art.Title, h1)) {
+			art.Title = h1
+		}
+	}
+	// 正文开头与标题相同的一级标题去掉，避免重复
+	if art.Title != "" {
+		md = strings.TrimSpace(strings.TrimPrefix(md, "# "+art.Title))
+	}
+	art.Markdown = md
+	return art, nil
+}
+
+// prune 删除脚本、导航等节点以及 class/id 明显不是正文的节点
+func prune(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			if dropTags[c.DataAtom] || isUnlikely(c) {
+				n.RemoveChild(c)
+			} else {
+				prune(c)
+			}
+		}
+		c = next
+	}
+}
+
+func isUnlikely(n *html.Node) bool {
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	sig := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyRe.MatchString(sig) && !positiveRe.MatchString(sig)
+}
+
+// pickContent 按段落给祖先节点打分，返回得分最高的容器
+func pickContent(body *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	order := make([]*html.Node, 0)
+	addScore := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = baseScore(n)
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+	walk(body, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		default:
+			return
+		}
+		text := strings.TrimSpace(textOf(n))
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+		s := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")+strings.Count(text, "。"))
+		s += float64(min(length/100, 3))
+		addScore(n.Parent, s)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, s/2)
+		}
+	})
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		s := scores[n] * (1 - linkDensity(n))
+		if s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	return best
+}
+
+func baseScore(n *html.Node) float64 {
+	s := 0.0
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		s += 10
+	case atom.Div:
+		s += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		s += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		s -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		s -= 5
+	}
+	sig := attr(n, "class") + " " + attr(n, "id")
+	if positiveRe.MatchString(sig) {
+		s += 25
+	}
+	if unlikelyRe.MatchString(sig) {
+		s -= 25
+	}
+	return s
+}
+
+// linkDensity 链接文字占全部文字的比例
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(strings.TrimSpace(textOf(n)))
+	if total == 0 {
+		return 1
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(strings.TrimSpace(textOf(c)))
+		}
+	})
+	return float64(linked) / float64(total)
+}
+
+func findTitle(doc *html.Node) string {
+	if t := metaContent(doc, "og:title"); t != "" {
+		return t
+	}
+	if t := findFirst(doc, atom.Title); t != nil {
+		return strings.TrimSpace(textOf(t))
+	}
+	return ""
+}
+
+func metaContent(doc *html.Node, property string) string {
+	var out string
+	walk(doc, func(n *html.Node) {
+		if out != "" || n.Type != html.ElementNode || n.DataAtom != atom.Meta {
+			return
+		}
+		if attr(n, "property") == property || attr(n, "name") == property {
+			out = strings.TrimSpace(attr(n, "content"))
+		}
+	})
+	return out
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if f := findFirst(c, a); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	})
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 业务码：对外稳定，前端据此判断错误类型，不要复用或修改已发布的取值。
+// 取值为 HTTP 状态 * 100 + 序号，整百为该类别的通用码；0 表示成功，1 为旧版通用失败码
+const (
+	CodeOK   = 0
+	CodeFail = 1
+
+	CodeInvalidParam      = 40000
+	CodeInvalidID         = 40001
+	CodeTitleRequired     = 40002
+	CodeContentRequired   = 40003
+	CodeQueryRequired     = 40004
+	CodeQuestionRequired  = 40005
+	CodeBatchAction       = 40006
+	CodeBatchEmpty        = 40007
+	CodeBatchTooMany      = 40008
+	CodeTagRequired       = 40009
+	CodeInvalidURL        = 40010
+	CodeAttachmentEmpty   = 40011
+	CodeAttachmentMissing = 40012
+	CodeInvalidCursor     = 40013
+	CodeInvalidSort       = 40014
+	CodeMergeSources      = 40015
+	CodeMergeTooMany      = 40016
+
+	CodeNotFound           = 40400
+	CodeNoteNotFound       = 40401
+	CodeAttachmentNotFound = 40402
+
+	CodeConflict       = 40900
+	CodeNoteInTrash    = 40901
+	CodeNoteNotInTrash = 40902
+
+	CodeTooLarge        = 41300
+	CodeUploadTooLarge  = 41301
+	CodeQuestionTooLong = 41302
+
+	CodeUnsupported     = 41500
+	CodeUnsupportedType = 41501
+
+	CodeUnprocessable = 42200
+	CodeClipNoContent = 42201
+
+	CodeInternal = 50000
+	CodeUpstream = 50200
+
+	CodeUnavailable    = 50300
+	CodeLLMDisabled    = 50301
+	CodeVectorDisabled = 50302
+)
+
+// 支持的语言，默认中文
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// messages 多语言消息模板，按 fmt 格式填充参数
+var messages = map[string]map[int]string{
+	LangZH: {
+		CodeOK:                 "success",
+		CodeFail:               "操作失败",
+		CodeInvalidParam:       "参数错误:%s",
+		CodeInvalidID:          "ID不合法(必须为正整数)",
+		CodeTitleRequired:      "笔记标题不能为空",
+		CodeContentRequired:    "内容不能为空",
+		CodeQueryRequired:      "缺少搜索关键词",
+		CodeQuestionRequired:   "缺少问题",
+		CodeBatchAction:        "不支持的批量操作:%s",
+		CodeBatchEmpty:         "笔记ID列表不能为空",
+		CodeBatchTooMany:       "单次批量操作最多%d条笔记",
+		CodeTagRequired:        "标签不能为空",
+		CodeInvalidURL:         "原文地址格式错误",
+		CodeAttachmentEmpty:    "附件内容为空",
+		CodeAttachmentMissing:  "缺少附件(表单字段 file)",
+		CodeInvalidCursor:      "分页游标无效或与排序方式不符",
+		CodeInvalidSort:        "不支持的排序方式:%s",
+		CodeMergeSources:       "被合并的笔记不能为空，且不能包含目标笔记",
+		CodeMergeTooMany:       "单次最多合并%d条笔记",
+		CodeNotFound:           "资源不存在",
+		CodeNoteNotFound:       "未找到该笔记(可能已删除或ID不存在)",
+		CodeAttachmentNotFound: "未找到该附件",
+		CodeConflict:           "当前状态不允许该操作",
+		CodeNoteInTrash:        "笔记已在回收站中",
+		CodeNoteNotInTrash:     "笔记不在回收站中",
+		CodeTooLarge:           "请求内容过大",
+		CodeUploadTooLarge:     "附件超过大小限制(最大 %dMB)",
+		CodeQuestionTooLong:    "问题过长，超出模型上下文窗口(最多约 %d tokens)",
+		CodeUnsupported:        "不支持的内容类型",
+		CodeUnsupportedType:    "不支持的附件类型:%s",
+		CodeUnprocessable:      "无法处理的内容",
+		CodeClipNoContent:      "未能从页面中提取正文",
+		CodeInternal:           "服务内部错误，请稍后重试",
+		CodeUpstream:           "依赖服务暂不可用，请稍后重试",
+		CodeUnavailable:        "服务暂不可用",
+		CodeLLMDisabled:        "未配置 LLM 服务(llm.url)",
+		CodeVectorDisabled:     "未配置向量库(rag.pinecone_host)",
+	},
+	LangEN: {
+		CodeOK:                 "success",
+		CodeFail:               "operation failed",
+		CodeInvalidParam:       "invalid parameter: %s",
+		CodeInvalidID:          "invalid ID (must be a positive integer)",
+		CodeTitleRequired:      "note title is required",
+		CodeContentRequired:    "content is required",
+		CodeQueryRequired:      "search keyword is required",
+		CodeQuestionRequired:   "question is required",
+		CodeBatchAction:        "unsupported batch action: %s",
+		CodeBatchEmpty:         "note ID list is empty",
+		CodeBatchTooMany:       "at most %d notes per batch",
+		CodeTagRequired:        "tags are required",
+		CodeInvalidURL:         "invalid source URL",
+		CodeAttachmentEmpty:    "attachment is empty",
+		CodeAttachmentMissing:  "attachment is missing (form field file)",
+		CodeInvalidCursor:      "invalid pagination cursor or it does not match the sort order",
+		CodeInvalidSort:        "unsupported sort option: %s",
+		CodeMergeSources:       "notes to merge must be non-empty and must not include the target note",
+		CodeMergeTooMany:       "at most %d notes can be merged at once",
+		CodeNotFound:           "resource not found",
+		CodeNoteNotFound:       "note not found (deleted or nonexistent ID)",
+		CodeAttachmentNotFound: "attachment not found",
+		CodeConflict:           "operation not allowed in the current state",
+		CodeNoteInTrash:        "note is already in the trash",
+		CodeNoteNotInTrash:     "note is not in the trash",
+		CodeTooLarge:           "request entity too large",
+		CodeUploadTooLarge:     "attachment exceeds the size limit (max %dMB)",
+		CodeQuestionTooLong:    "question is too long for the model context window (about %d tokens max)",
+		CodeUnsupported:        "unsupported media type",
+		CodeUnsupportedType:    "unsupported attachment type: %s",
+		CodeUnprocessable:      "unprocessable content",
+		CodeClipNoContent:      "no article content could be extracted from the page",
+		CodeInternal:           "internal server error, please retry later",
+		CodeUpstream:           "a dependent service is unavailable, please retry later",
+		CodeUnavailable:        "service unavailable",
+		CodeLLMDisabled:        "no LLM service is configured (llm.url)",
+		CodeVectorDisabled:     "no vector store is configured (rag.pinecone_host)",
+	},
+}
+
+// Message 按语言渲染业务码对应的消息，缺少译文时回退中文，未知业务码回退所属类别的通用消息
+func Message(lang string, code int, args ...interface{}) string {
+	catalog, ok := messages[lang]
+	if !ok {
+		catalog = messages[LangZH]
+	}
+	tmpl, ok := catalog[code]
+	if !ok {
+		tmpl, ok = messages[LangZH][code]
+	}
+	if !ok {
+		if tmpl, ok = catalog[code/100*100]; !ok {
+			tmpl = catalog[CodeFail]
+		}
+		args = nil
+	}
+	if len(args) == 0 {
+		// 没有参数时去掉模板里的占位部分，避免输出 %!s(MISSING)
+		if i := strings.Index(tmpl, "%"); i >= 0 {
+			head := tmpl[:i]
+			if j := strings.LastIndexAny(head, ":：("); j >= 0 {
+				head = head[:j]
+			}
+			return strings.TrimSpace(head)
+		}
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// ParseLang 从 Accept-Language 中选出支持的语言（按 q 值优先），都不支持时返回中文
+func ParseLang(header string) string {
+	best, bestQ := LangZH, -1.0
+	for _, part := range strings.Split(header, ",") {
+		tag, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(tag, ";"); i >= 0 {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(tag[i+1:]), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			tag = tag[:i]
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[primary]; ok && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
@@ -24,3 +24,12 @@ func Fail(msg string) Response {
 		nil,
 	}
 }
+
+// Error 失败响应，code 为业务码，msg 为已本地化的消息
+func Error(code int, msg string) Response {
+	return Response{
+		code,
+		msg,
+		nil,
+	}
+}
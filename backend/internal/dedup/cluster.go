@@ -0,0 +1,46 @@
+package dedup
+
+import "sort"
+
+// Clusters 把两两相连的ID并成连通分量（并查集），每个分量按ID升序，分量之间按首个ID升序
+func Clusters(pairs [][2]int64) [][]int64 {
+	parent := make(map[int64]int64)
+	var find func(x int64) int64
+	find = func(x int64) int64 {
+		p, ok := parent[x]
+		if !ok {
+			parent[x] = x
+			return x
+		}
+		if p == x {
+			return x
+		}
+		root := find(p)
+		parent[x] = root
+		return root
+	}
+	for _, p := range pairs {
+		a, b := find(p[0]), find(p[1])
+		if a == b {
+			continue
+		}
+		// 以较小的ID为根，结果与输入顺序无关
+		if a < b {
+			parent[b] = a
+		} else {
+			parent[a] = b
+		}
+	}
+	groups := make(map[int64][]int64)
+	for x := range parent {
+		root := find(x)
+		groups[root] = append(groups[root], x)
+	}
+	out := make([][]int64, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
@@ -0,0 +1,43 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusters(t *testing.T) {
+	cases := []struct {
+		name  string
+		pairs [][2]int64
+		want  [][]int64
+	}{
+		{"空", nil, [][]int64{}},
+		{"单对", [][2]int64{{2, 1}}, [][]int64{{1, 2}}},
+		{"传递合并", [][2]int64{{5, 9}, {1, 3}, {9, 3}, {7, 8}}, [][]int64{{1, 3, 5, 9}, {7, 8}}},
+		{"重复与自环", [][2]int64{{4, 4}, {4, 6}, {6, 4}}, [][]int64{{4, 6}}},
+		{"链式后合并两簇", [][2]int64{{10, 11}, {20, 21}, {11, 20}}, [][]int64{{10, 11, 20, 21}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Clusters(c.pairs); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// 结果与输入顺序无关
+func TestClustersOrderIndependent(t *testing.T) {
+	pairs := [][2]int64{{3, 8}, {8, 2}, {6, 5}, {2, 7}, {9, 5}}
+	want := Clusters(pairs)
+	reversed := make([][2]int64, len(pairs))
+	for i, p := range pairs {
+		reversed[len(pairs)-1-i] = [2]int64{p[1], p[0]}
+	}
+	if got := Clusters(reversed); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(want, [][]int64{{2, 3, 7, 8}, {5, 6, 9}}) {
+		t.Fatalf("got %v", want)
+	}
+}
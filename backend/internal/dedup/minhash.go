@@ -0,0 +1,91 @@
+// Package dedup 重复笔记检测用到的文本指纹、MinHash 与聚类算法，不依赖存储
+package dedup
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"note-system/internal/textutil"
+	"strings"
+)
+
+const (
+	// NumHashes MinHash 签名长度，估计 Jaccard 相似度的标准差约为 1/sqrt(NumHashes)
+	NumHashes = 64
+	// LSH 分桶：bands*rows = NumHashes；相似度 0.8 的两条笔记至少落入同一桶的概率约 99.9%
+	bands = 16
+	rows  = NumHashes / bands
+	// MinTerms 词元少于该值的笔记不做 MinHash 比较，太短的文本相似度估计不可靠
+	MinTerms = 8
+)
+
+// Fingerprint 正文纯文本（含代码）规整空白、转小写后的 SHA-1，只有排版不同的笔记指纹相同
+func Fingerprint(md string) string {
+	text := strings.ToLower(textutil.Plain(md, true))
+	h := sha1.Sum([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(h[:])
+}
+
+// Signature 一段文本词元集合的 MinHash 签名
+type Signature [NumHashes]uint32
+
+// NewSignature 按 textutil.Terms 的词元集合计算签名；每个词元做一次 64 位哈希，
+// 高低 32 位组合出 NumHashes 个哈希函数（Kirsch-Mitzenmacher 双重哈希）
+func NewSignature(terms []string) Signature {
+	var sig Signature
+	for i := range sig {
+		sig[i] = math.MaxUint32
+	}
+	for _, t := range terms {
+		h := fnv.New64a()
+		h.Write([]byte(t))
+		sum := h.Sum64()
+		h1, h2 := uint32(sum), uint32(sum>>32)|1
+		for i := range sig {
+			if v := h1 + uint32(i)*h2; v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity 两个签名估计的 Jaccard 相似度
+func (s Signature) Similarity(o Signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == o[i] {
+			same++
+		}
+	}
+	return float64(same) / NumHashes
+}
+
+// Candidates 用 LSH 分桶找出可能相似的签名对（下标），每对只出现一次且 i < j；
+// 调用方再用 Similarity 过滤
+func Candidates(sigs []Signature) [][2]int {
+	seen := make(map[[2]int]struct{})
+	out := make([][2]int, 0)
+	for b := 0; b < bands; b++ {
+		buckets := make(map[[rows]uint32][]int, len(sigs))
+		for i, s := range sigs {
+			var key [rows]uint32
+			copy(key[:], s[b*rows:(b+1)*rows])
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, members := range buckets {
+			for x := 0; x < len(members); x++ {
+				for y := x + 1; y < len(members); y++ {
+					p := [2]int{members[x], members[y]}
+					if _, ok := seen[p]; ok {
+						continue
+					}
+					seen[p] = struct{}{}
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
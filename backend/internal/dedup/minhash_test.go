@@ -0,0 +1,81 @@
+package dedup
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func terms(prefix string, from, to int) []string {
+	out := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, fmt.Sprintf("%s%d", prefix, i))
+	}
+	return out
+}
+
+func TestFingerprintIgnoresFormatting(t *testing.T) {
+	a := Fingerprint("# 标题\n\nGo   语言的 **并发** 模型。\n")
+	b := Fingerprint("# 标题\nGo 语言的 并发 模型。")
+	if a != b {
+		t.Fatal("只有空白与强调标记不同的笔记指纹应相同")
+	}
+	if a == Fingerprint("# 标题\n\nGo 语言的并发模型不同。") {
+		t.Fatal("内容不同的笔记指纹不应相同")
+	}
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	base := terms("t", 0, 100)
+	if s := NewSignature(base).Similarity(NewSignature(base)); s != 1 {
+		t.Fatalf("相同词元集合的相似度 %g", s)
+	}
+	// 词元顺序与重复不影响签名
+	shuffled := append(append([]string{}, base[50:]...), base[:50]...)
+	shuffled = append(shuffled, base[:10]...)
+	if NewSignature(shuffled) != NewSignature(base) {
+		t.Fatal("词元顺序或重复改变了签名")
+	}
+	if s := NewSignature(base).Similarity(NewSignature(terms("u", 0, 100))); s > 0.1 {
+		t.Fatalf("不相交集合的相似度 %g", s)
+	}
+	// 重合 80 个、各自独有 20 个：Jaccard = 80/120，估计误差在 3 个标准差以内
+	other := append(terms("t", 20, 100), terms("v", 0, 20)...)
+	want := 80.0 / 120
+	if s := NewSignature(base).Similarity(NewSignature(other)); math.Abs(s-want) > 3/math.Sqrt(NumHashes) {
+		t.Fatalf("相似度 %g, want ≈%g", s, want)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	sigs := []Signature{
+		NewSignature(terms("a", 0, 100)),
+		NewSignature(terms("b", 0, 100)),
+		NewSignature(append(terms("a", 0, 95), terms("c", 0, 5)...)),
+		NewSignature(terms("a", 0, 100)),
+	}
+	got := make(map[[2]int]bool)
+	for _, p := range Candidates(sigs) {
+		if p[0] >= p[1] {
+			t.Fatalf("候选对 %v 未按 i<j 排列", p)
+		}
+		if got[p] {
+			t.Fatalf("候选对 %v 重复", p)
+		}
+		got[p] = true
+	}
+	// 0、2、3 两两高度相似，必然落入同一桶；1 与其他笔记毫不相关
+	for _, p := range [][2]int{{0, 2}, {0, 3}, {2, 3}} {
+		if !got[p] {
+			t.Errorf("缺少候选对 %v，got %v", p, got)
+		}
+	}
+	for p := range got {
+		if p[0] == 1 || p[1] == 1 {
+			t.Errorf("不相关的笔记出现在候选对 %v 中", p)
+		}
+	}
+	if len(Candidates(nil)) != 0 || len(Candidates(sigs[:1])) != 0 {
+		t.Fatal("少于两个签名时不应有候选对")
+	}
+}
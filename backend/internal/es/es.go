@@ -0,0 +1,147 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"note-system/config"
+	"note-system/internal/httpx"
+	"note-system/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client ElasticSearch 笔记索引的读写
+type Client struct {
+	url   string
+	index string
+	http  *httpx.Client
+}
+
+func New(cfg config.ESConfig) *Client {
+	return &Client{
+		url:   strings.TrimRight(cfg.URL, "/"),
+		index: cfg.Index,
+		http:  httpx.New(httpx.Options{Name: "es", Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}),
+	}
+}
+
+// IndexNote 写入（覆盖）单条笔记文档
+func (c *Client) IndexNote(ctx context.Context, note *model.Note) error {
+	if note == nil {
+		return nil
+	}
+	req := httpx.Request{Op: "index", Method: http.MethodPut, URL: c.url + "/" + c.index + "/_doc/" + strconv.FormatInt(note.ID, 10)}
+	return c.http.JSON(ctx, req, noteDoc(note), nil)
+}
+
+// IndexNotes 通过 _bulk 接口一次性写入多条笔记
+func (c *Client) IndexNotes(ctx context.Context, notes []*model.Note) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, note := range notes {
+		if note == nil {
+			continue
+		}
+		_ = enc.Encode(map[string]interface{}{"index": map[string]interface{}{"_index": c.index, "_id": strconv.FormatInt(note.ID, 10)}})
+		_ = enc.Encode(noteDoc(note))
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	req := httpx.Request{Op: "bulk", Method: http.MethodPost, URL: c.url + "/_bulk", Header: http.Header{}, Body: buf.Bytes()}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := c.http.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	// _bulk 整体返回 200，单条失败记录在 errors/items 中
+	var out struct {
+		Errors bool `json:"errors"`
+	}
+	if json.Unmarshal(resp.Body, &out) == nil && out.Errors {
+		return fmt.Errorf("es: _bulk 部分文档写入失败")
+	}
+	return nil
+}
+
+// DeleteNote 删除单条笔记文档，文档不存在视为成功
+func (c *Client) DeleteNote(ctx context.Context, id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := c.http.Do(ctx, httpx.Request{Op: "delete", Method: http.MethodDelete, URL: c.url + "/" + c.index + "/_doc/" + strconv.FormatInt(id, 10)})
+	if httpx.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteNotes 通过 _delete_by_query 一次性删除多条笔记
+func (c *Client) DeleteNotes(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return c.deleteByQuery(ctx, map[string]interface{}{"terms": map[string]interface{}{"id": ids}})
+}
+
+// DeleteAll 清空索引中的全部文档
+func (c *Client) DeleteAll(ctx context.Context) error {
+	return c.deleteByQuery(ctx, map[string]interface{}{"match_all": map[string]interface{}{}})
+}
+
+func (c *Client) deleteByQuery(ctx context.Context, query map[string]interface{}) error {
+	req := httpx.Request{Op: "delete_by_query", Method: http.MethodPost, URL: c.url + "/" + c.index + "/_delete_by_query"}
+	err := c.http.JSON(ctx, req, map[string]interface{}{"query": query}, nil)
+	if httpx.IsNotFound(err) {
+		// 索引尚未创建
+		return nil
+	}
+	return err
+}
+
+// Search 执行查询，返回命中文档的 _source
+func (c *Client) Search(ctx context.Context, query map[string]interface{}, size int) ([]map[string]interface{}, error) {
+	var out struct {
+		Hits struct {
+			Hits []struct {
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	req := httpx.Request{Op: "search", Method: http.MethodPost, URL: c.url + "/" + c.index + "/_search"}
+	if err := c.http.JSON(ctx, req, map[string]interface{}{"query": query, "size": size}, &out); err != nil {
+		return nil, err
+	}
+	list := make([]map[string]interface{}, 0, len(out.Hits.Hits))
+	for _, h := range out.Hits.Hits {
+		if h.Source != nil {
+			list = append(list, h.Source)
+		}
+	}
+	return list, nil
+}
+
+// Count 返回索引中的文档数，用于健康检查与数量核对
+func (c *Client) Count(ctx context.Context) (int64, error) {
+	var out struct {
+		Count int64 `json:"count"`
+	}
+	req := httpx.Request{Op: "count", Method: http.MethodGet, URL: c.url + "/" + c.index + "/_count"}
+	if err := c.http.JSON(ctx, req, nil, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+func noteDoc(note *model.Note) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         note.ID,
+		"title":      note.Title,
+		"content":    note.Content,
+		"updated_at": note.UpdatedAt,
+	}
+}
@@ -0,0 +1,71 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported 该类型的附件不做文本抽取
+var ErrUnsupported = errors.New("unsupported content type")
+
+// 单个附件最多读取的字节数
+const maxInput = 64 << 20
+
+// Page 抽取出的一页文本，非分页文档只有第 1 页
+type Page struct {
+	Number int
+	Text   string
+}
+
+// Supported 判断 MIME 类型是否支持文本抽取
+func Supported(mime string) bool {
+	switch {
+	case mime == "application/pdf", mime == "text/html", mime == "application/json":
+		return true
+	case strings.HasPrefix(mime, "text/"):
+		return true
+	}
+	return false
+}
+
+// Extract 按 MIME 类型从附件内容中抽取纯文本
+func Extract(mime string, r io.Reader) ([]Page, error) {
+	if !Supported(mime) {
+		return nil, ErrUnsupported
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxInput))
+	if err != nil {
+		return nil, err
+	}
+	var pages []Page
+	switch mime {
+	case "application/pdf":
+		pages, err = extractPDF(data)
+	case "text/html":
+		pages = []Page{{Number: 1, Text: HTMLText(data)}}
+	default:
+		pages = []Page{{Number: 1, Text: plainText(data)}}
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := pages[:0]
+	for _, p := range pages {
+		p.Text = strings.TrimSpace(p.Text)
+		if p.Text != "" {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func plainText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return strings.ToValidUTF8(string(data), "")
+	}
+	return string(data)
+}
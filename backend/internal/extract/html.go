@@ -0,0 +1,93 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 不含正文的标签，整棵子树跳过
+var skipTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Head: true, atom.Iframe: true, atom.Svg: true, atom.Canvas: true,
+}
+
+// 块级标签前后换行，保证段落在切分时仍然分开
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Pre: true, atom.Blockquote: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Ul: true, atom.Ol: true, atom.Table: true,
+}
+
+// HTMLText 提取 HTML 中的可见文本，块级元素之间以空行分隔
+func HTMLText(data []byte) string {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return plainText(data)
+	}
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skipTags[n.DataAtom] {
+			return
+		}
+		if n.Type == html.TextNode {
+			sb.WriteString(squashSpaces(n.Data))
+			return
+		}
+		block := n.Type == html.ElementNode && blockTags[n.DataAtom]
+		if block {
+			sb.WriteString("\n\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			sb.WriteString("\n\n")
+		}
+	}
+	walk(doc)
+	return collapseBlankLines(sb.String())
+}
+
+// squashSpaces 连续空白合并为一个空格，保留首尾是否有空白
+func squashSpaces(s string) string {
+	text := strings.Join(strings.Fields(s), " ")
+	if text == "" {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s {
+		text = " " + text
+	}
+	if strings.TrimRightFunc(s, unicode.IsSpace) != s {
+		text += " "
+	}
+	return text
+}
+
+// collapseBlankLines 去掉行首尾空白，多个空行合并为一个
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, l := range lines {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, l)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,34 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// extractPDF 逐页抽取 PDF 文本；解析库遇到损坏文件可能 panic，这里统一转为错误
+func extractPDF(data []byte) (pages []Page, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("解析 PDF 失败: %v", r)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	pages = make([]Page, 0, reader.NumPage())
+	for i := 1; i <= reader.NumPage(); i++ {
+		p := reader.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		text, err := p.GetPlainText(nil)
+		if err != nil {
+			continue
+		}
+		pages = append(pages, Page{Number: i, Text: text})
+	}
+	return pages, nil
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"note-system/internal/common"
+	"note-system/internal/extract"
+	"note-system/internal/logging"
+	"note-system/internal/model"
+	"note-system/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadAttachment 上传附件（POST /api/note/:id/attachments，表单字段 file）
+func (h *NoteHandler) UploadAttachment(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	// 预留 1MB 给表单其余部分
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.attachments.MaxSize()+1<<20)
+	fh, err := c.FormFile("file")
+	switch {
+	case errors.As(err, new(*http.MaxBytesError)):
+		fail(c, service.TooLarge(common.CodeUploadTooLarge, h.attachments.MaxSize()>>20))
+		return
+	case errors.Is(err, http.ErrMissingFile):
+		fail(c, service.Validation(common.CodeAttachmentMissing))
+		return
+	case err != nil:
+		badParam(c, err)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		badParam(c, err)
+		return
+	}
+	defer f.Close()
+	att, err := h.attachments.Upload(id, fh.Filename, f)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	// 只索引新上传的附件，笔记已有附件的片段不受影响
+	if note, e := h.svc.GetNoteById(id); e == nil && h.rag != nil {
+		h.indexAttachment(h.syncCtx(c), note, att)
+	}
+	c.JSON(http.StatusOK, common.Success(att))
+}
+
+// ListAttachments 查询笔记的附件列表（GET /api/note/:id/attachments）
+func (h *NoteHandler) ListAttachments(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.attachments.List(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Success(map[string]interface{}{"list": list}))
+}
+
+// DownloadAttachment 下载附件（GET /api/attachment/:id）
+func (h *NoteHandler) DownloadAttachment(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	att, rc, err := h.attachments.Open(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	defer rc.Close()
+	// 仅图片与 PDF 内联展示，其余类型（含 HTML）一律作为下载，避免在本站域下执行
+	disposition := "attachment"
+	if (strings.HasPrefix(att.MIME, "image/") && att.MIME != "image/svg+xml") || att.MIME == "application/pdf" {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, att.Size, att.MIME, rc, map[string]string{
+		"Content-Disposition":    disposition + "; filename*=UTF-8''" + url.PathEscape(att.FileName),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=31536000, immutable",
+	})
+}
+
+// DeleteAttachment 删除附件（DELETE /api/attachment/:id）
+func (h *NoteHandler) DeleteAttachment(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	if h.rag != nil {
+		ctx := h.syncCtx(c)
+		logging.WarnIf(ctx, h.rag.DeleteAttachmentFragments(ctx, id), "删除附件片段失败", "attachment_id", id)
+	}
+	if err := h.attachments.Delete(id); err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Success(nil))
+}
+
+// indexAttachments 抽取笔记下 PDF/HTML/文本附件的内容并写入 RAG 索引（忽略错误）
+func (h *NoteHandler) indexAttachments(ctx context.Context, notes []*model.Note) {
+	if h.rag == nil || h.attachments == nil || len(notes) == 0 {
+		return
+	}
+	ids := make([]int64, 0, len(notes))
+	byID := make(map[int64]*model.Note, len(notes))
+	for _, n := range notes {
+		ids = append(ids, n.ID)
+		byID[n.ID] = n
+	}
+	atts, err := h.attachments.ListByNotes(ids)
+	if err != nil {
+		logging.WarnIf(ctx, err, "查询笔记附件失败", "note_ids", ids)
+		return
+	}
+	for i := range atts {
+		h.indexAttachment(ctx, byID[atts[i].NoteID], &atts[i])
+	}
+}
+
+// indexAttachment 抽取单个附件的文本并写入向量索引，不支持的类型直接跳过
+func (h *NoteHandler) indexAttachment(ctx context.Context, note *model.Note, att *model.Attachment) {
+	pages, err := h.attachments.ExtractText(att)
+	if errors.Is(err, extract.ErrUnsupported) {
+		return
+	}
+	if err != nil {
+		logging.WarnIf(ctx, err, "抽取附件文本失败", "attachment_id", att.ID)
+		return
+	}
+	logging.WarnIf(ctx, h.rag.IndexAttachment(ctx, note, att, pages), "向量索引附件失败", "attachment_id", att.ID, "note_id", att.NoteID)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"note-system/internal/clip"
+	"note-system/internal/common"
+	"note-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 剪藏页面 HTML 的大小上限
+const maxClipHTML = 5 << 20
+
+// ClipPage 网页剪藏接口（POST /api/clip）
+// 由浏览器扩展提交页面 HTML 与原文地址，服务端不访问网络：抽取正文转为 Markdown 后创建笔记
+func (h *NoteHandler) ClipPage(c *gin.Context) {
+	var req struct {
+		HTML  string `json:"html" binding:"required"`
+		URL   string `json:"url" binding:"required"`
+		Title string `json:"title"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxClipHTML+1<<20)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badParam(c, err)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail(c, service.Validation(common.CodeInvalidURL))
+		return
+	}
+	art, err := clip.Extract([]byte(req.HTML), u.String())
+	if err != nil {
+		fail(c, service.Unprocessable(common.CodeClipNoContent))
+		return
+	}
+	title := req.Title
+	if title == "" {
+		title = art.Title
+	}
+	source := u.String()
+	if art.SiteName != "" {
+		source = art.SiteName + " · " + source
+	}
+	content := art.Markdown + "\n\n---\n\n> 剪藏自：" + source
+
+	note, err := h.svc.CreateClippedNote(title, content, u.String())
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	h.syncNote(h.syncCtx(c), note)
+	c.JSON(http.StatusOK, common.Success(note))
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"note-system/internal/common"
+	"note-system/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// kindStatus 业务错误类别对应的 HTTP 状态
+var kindStatus = map[service.Kind]int{
+	service.KindInternal:      http.StatusInternalServerError,
+	service.KindValidation:    http.StatusBadRequest,
+	service.KindNotFound:      http.StatusNotFound,
+	service.KindConflict:      http.StatusConflict,
+	service.KindTooLarge:      http.StatusRequestEntityTooLarge,
+	service.KindUnsupported:   http.StatusUnsupportedMediaType,
+	service.KindUnprocessable: http.StatusUnprocessableEntity,
+	service.KindUpstream:      http.StatusBadGateway,
+	service.KindUnavailable:   http.StatusServiceUnavailable,
+}
+
+// ErrorMiddleware 统一错误出口：把处理函数通过 c.Error 记录的错误转换为 HTTP 状态、
+// 业务码与按 Accept-Language（或 ?lang=）本地化的消息；底层原因由访问日志记录，不返回给客户端
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		var e *service.Error
+		if !errors.As(c.Errors.Last().Err, &e) {
+			e = service.Internal("未分类错误", c.Errors.Last().Err)
+		}
+		status, ok := kindStatus[e.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, common.Error(e.Code, common.Message(lang(c), e.Code, e.Args...)))
+	}
+}
+
+// lang 请求使用的语言，?lang= 优先于 Accept-Language
+func lang(c *gin.Context) string {
+	if l := c.Query("lang"); l != "" {
+		return common.ParseLang(l)
+	}
+	return common.ParseLang(c.GetHeader("Accept-Language"))
+}
+
+// fail 记录错误并交给 ErrorMiddleware 输出响应
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+}
+
+// badParam 参数绑定或解析失败
+func badParam(c *gin.Context, err error) {
+	fail(c, service.Validation(common.CodeInvalidParam, err.Error()))
+}
+
+// parseID 解析路径参数中的正整数ID，失败时已写入错误
+func parseID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		fail(c, service.Validation(common.CodeInvalidID))
+		return 0, false
+	}
+	return id, true
+}
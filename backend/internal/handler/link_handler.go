@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"note-system/internal/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Backlinks 查询引用了该笔记的笔记（GET /api/note/:id/backlinks）
+func (h *NoteHandler) Backlinks(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.links.Backlinks(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Success(map[string]interface{}{"list": list}))
+}
+
+// Outlinks 查询该笔记引用的笔记，含悬空链接（GET /api/note/:id/outlinks）
+func (h *NoteHandler) Outlinks(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.links.Outlinks(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Success(map[string]interface{}{"list": list}))
+}
+
+// Graph 知识图谱数据（GET /api/graph）
+func (h *NoteHandler) Graph(c *gin.Context) {
+	g, err := h.links.Graph()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Success(g))
+}
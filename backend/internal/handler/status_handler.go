@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"note-system/internal/common"
+	"note-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusHandler 健康检查与依赖状态接口
+type StatusHandler struct {
+	svc *service.StatusService
+}
+
+func NewStatusHandler(svc *service.StatusService) *StatusHandler {
+	return &StatusHandler{svc: svc}
+}
+
+// Healthz 存活检查，进程能响应即返回 200（GET /healthz）
+func (h *StatusHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": service.StatusOK})
+}
+
+// Readyz 就绪检查，数据库不可用时返回 503（GET /readyz）
+func (h *StatusHandler) Readyz(c *gin.Context) {
+	if err := h.svc.Ready(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": service.StatusDown, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": service.StatusOK})
+}
+
+// Status 各依赖的可用性、延迟、模型配置与索引数量差异（GET /api/status）
+func (h *StatusHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Success(h.svc.Status(c.Request.Context())))
+}
@@ -0,0 +1,233 @@
+// Package httpx 外部服务（ES、Pinecone、TEI、LLM）的统一 HTTP 客户端：
+// 每次请求带上下文与超时，非 2xx 返回 *StatusError，429/5xx 按抖动退避重试，
+// 连续失败后熔断，避免一个挂掉的依赖拖慢所有请求
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"note-system/internal/logging"
+	"note-system/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// 响应体最多读取的字节数，防止异常响应占满内存
+const maxBodySize = 32 << 20
+
+// Options 客户端参数，零值字段使用默认值
+type Options struct {
+	// Name 服务名，出现在错误信息中
+	Name string
+	// Timeout 单次请求（每次重试单独计算）的超时，默认 10s
+	Timeout time.Duration
+	// MaxRetries 429/5xx/网络错误时的最大重试次数，默认 2，<0 表示不重试
+	MaxRetries int
+	// RetryBaseDelay 退避基准时间，第 n 次重试等待 [0, base*2^n) 的随机时间，默认 200ms
+	RetryBaseDelay time.Duration
+	// BreakerThreshold 连续失败多少次后熔断，默认 5，<0 表示不熔断
+	BreakerThreshold int
+	// BreakerCooldown 熔断持续时间，之后放行一个试探请求，默认 30s
+	BreakerCooldown time.Duration
+}
+
+// Client 某个外部服务的客户端，可并发使用
+type Client struct {
+	name    string
+	http    *http.Client
+	opt     Options
+	breaker *breaker
+}
+
+func New(opt Options) *Client {
+	if opt.Timeout <= 0 {
+		opt.Timeout = 10 * time.Second
+	}
+	if opt.MaxRetries == 0 {
+		opt.MaxRetries = 2
+	}
+	if opt.RetryBaseDelay <= 0 {
+		opt.RetryBaseDelay = 200 * time.Millisecond
+	}
+	if opt.BreakerThreshold == 0 {
+		opt.BreakerThreshold = 5
+	}
+	if opt.BreakerCooldown <= 0 {
+		opt.BreakerCooldown = 30 * time.Second
+	}
+	c := &Client{name: opt.Name, http: &http.Client{}, opt: opt}
+	if opt.BreakerThreshold > 0 {
+		c.breaker = &breaker{threshold: opt.BreakerThreshold, cooldown: opt.BreakerCooldown}
+	}
+	return c
+}
+
+// Name 返回服务名
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Request 一次外部调用。Body 以字节形式给出，重试时可以重复发送
+type Request struct {
+	// Op 操作名（如 upsert、query），用于指标标签
+	Op     string
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Timeout 覆盖客户端默认的单次超时（如来自可热加载的配置），<=0 时使用默认值
+	Timeout time.Duration
+}
+
+// Response 已完整读取的响应
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do 发送请求。非 2xx 响应返回 *StatusError（同时返回 Response，便于调用方放行 404 等状态）
+func (c *Client) Do(ctx context.Context, req Request) (*Response, error) {
+	if c.breaker != nil && !c.breaker.allow() {
+		metrics.ObserveExternal(c.name, req.Op, "circuit_open", 0, 0)
+		return nil, &CircuitOpenError{Service: c.name}
+	}
+	start := time.Now()
+	var resp *Response
+	var err error
+	attempt := 0
+	for ; ; attempt++ {
+		resp, err = c.once(ctx, req)
+		if !retryable(ctx, resp, err) || attempt >= c.opt.MaxRetries {
+			break
+		}
+		wait := c.backoff(attempt, resp)
+		slog.DebugContext(ctx, "外部调用失败，准备重试", "service", c.name, "op", req.Op, "attempt", attempt+1, "wait", wait, "err", err)
+		if werr := sleep(ctx, wait); werr != nil {
+			break
+		}
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.ObserveExternal(c.name, req.Op, result, time.Since(start), attempt)
+	if c.breaker != nil {
+		// 只有依赖本身的故障计入熔断，调用方取消与 4xx 不算
+		switch {
+		case ctx.Err() != nil:
+			c.breaker.abort()
+		case retryable(ctx, resp, err):
+			c.breaker.failure()
+		default:
+			c.breaker.success()
+		}
+	}
+	return resp, err
+}
+
+// JSON 以 JSON 发送 in 并把响应解析到 out，in/out 为 nil 时分别不发送/不解析；req.Body 会被 in 覆盖
+func (c *Client) JSON(ctx context.Context, req Request, in, out interface{}) error {
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("%s: 序列化请求失败: %w", c.name, err)
+		}
+		req.Body = b
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(resp.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("%s: 解析响应失败: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *Client) once(ctx context.Context, r Request) (*Response, error) {
+	timeout := c.opt.Timeout
+	if r.Timeout > 0 {
+		timeout = r.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: 构造请求失败: %w", c.name, err)
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	// 透传请求ID，便于在网关或下游日志中关联
+	if id := logging.RequestID(ctx); id != "" && req.Header.Get(logging.HeaderRequestID) == "" {
+		req.Header.Set(logging.HeaderRequestID, id)
+	}
+	hresp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s %s: %w", c.name, r.Method, r.URL, err)
+	}
+	defer hresp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(hresp.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: 读取响应失败: %w", c.name, err)
+	}
+	resp := &Response{StatusCode: hresp.StatusCode, Header: hresp.Header, Body: b}
+	if hresp.StatusCode < 200 || hresp.StatusCode >= 300 {
+		return resp, &StatusError{Service: c.name, Method: r.Method, URL: r.URL, StatusCode: hresp.StatusCode, Body: string(b)}
+	}
+	return resp, nil
+}
+
+// retryable 网络错误、429 与 5xx 可重试；调用方已取消时不再重试
+func retryable(ctx context.Context, resp *Response, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+// backoff 指数退避加全抖动；429 带 Retry-After（秒）时以其为准
+func (c *Client) backoff(attempt int, resp *Response) time.Duration {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			return time.Duration(s) * time.Second
+		}
+	}
+	max := c.opt.RetryBaseDelay << attempt
+	return time.Duration(rand.Int63n(int64(max)) + 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 错误信息中保留的响应体长度
+const maxErrorBody = 512
+
+// StatusError 外部服务返回了非 2xx 状态码
+type StatusError struct {
+	Service    string
+	Method     string
+	URL        string
+	StatusCode int
+	// Body 响应体，便于排查（如 Pinecone 的维度不匹配提示）
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	body := e.Body
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+	return fmt.Sprintf("%s: %s %s 返回 %d: %s", e.Service, e.Method, e.URL, e.StatusCode, body)
+}
+
+// CircuitOpenError 服务处于熔断状态，请求未发出
+type CircuitOpenError struct {
+	Service string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return e.Service + ": 连续失败已熔断，暂停调用"
+}
+
+// IsStatus 判断 err 是否为指定状态码的 StatusError
+func IsStatus(err error, code int) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == code
+}
+
+// IsNotFound 判断 err 是否为 404
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
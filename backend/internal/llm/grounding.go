@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"note-system/internal/textutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minClaimUnits 有效词元少于该值的句子（如“综上。”）不参与核对
+const minClaimUnits = 3
+
+var citationRe = regexp.MustCompile(`\[(\d+)\]`)
+
+// listMarkerRe 行首的列表、标题或引用符号（如“- ”“## ”“> ”“1. ”“2) ”）；
+// orderedRe 只有序号的有序列表前缀，其后的句号不作为句末
+var (
+	listMarkerRe = regexp.MustCompile(`^\s*(?:[-*>#]+|\d+[.)])\s+`)
+	orderedRe    = regexp.MustCompile(`^\s*\d+\.$`)
+)
+
+// Claim 回答中的一句话及其被片段支持的程度
+type Claim struct {
+	Sentence string `json:"sentence"`
+	// Support 句中词元出现在片段中的比例，0~1
+	Support float64 `json:"support"`
+	// Source 支持度最高的片段在 Pack 结果中的下标，没有任何重合时为 -1
+	Source    int  `json:"source"`
+	Supported bool `json:"supported"`
+}
+
+// Grounding 回答的依据核对结果
+type Grounding struct {
+	// Score 各句支持度按句长加权的平均值
+	Score       float64 `json:"score"`
+	Unsupported int     `json:"unsupported"`
+	Claims      []Claim `json:"claims"`
+}
+
+// Ground 逐句核对回答是否有片段依据：按 textutil.Terms 把句子与片段切成词元，
+// 统计句中词元出现在片段中的比例，低于 minSupport 的句子标记为无依据。
+// 句子带有 [n] 引用时只与被引用的片段（按 frags 顺序从 1 编号）比较
+func Ground(answer string, frags []Fragment, minSupport float64) Grounding {
+	fragUnits := make([]map[string]struct{}, len(frags))
+	for i, f := range frags {
+		fragUnits[i] = unitSet(textutil.Terms(f.Text))
+	}
+	g := Grounding{Claims: make([]Claim, 0)}
+	weighted, total := 0.0, 0
+	for _, sentence := range splitSentences(answer) {
+		us := textutil.Terms(citationRe.ReplaceAllString(sentence, ""))
+		if len(us) < minClaimUnits {
+			continue
+		}
+		candidates := cited(sentence, len(frags))
+		claim := Claim{Sentence: sentence, Source: -1}
+		for _, i := range candidates {
+			if s := coverage(us, fragUnits[i]); s > claim.Support {
+				claim.Support, claim.Source = s, i
+			}
+		}
+		claim.Supported = claim.Support >= minSupport
+		if !claim.Supported {
+			g.Unsupported++
+		}
+		weighted += claim.Support * float64(len(us))
+		total += len(us)
+		g.Claims = append(g.Claims, claim)
+	}
+	if total > 0 {
+		g.Score = weighted / float64(total)
+	}
+	return g
+}
+
+// cited 句中引用的片段下标；没有有效引用时返回全部片段
+func cited(sentence string, n int) []int {
+	out := make([]int, 0)
+	for _, m := range citationRe.FindAllStringSubmatch(sentence, -1) {
+		if k, err := strconv.Atoi(m[1]); err == nil && k >= 1 && k <= n {
+			out = append(out, k-1)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	out = make([]int, n)
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}
+
+func coverage(us []string, set map[string]struct{}) float64 {
+	hit := 0
+	for _, u := range us {
+		if _, ok := set[u]; ok {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(us))
+}
+
+// splitSentences 按中英文句末标点与换行切句，去掉列表符号等前缀，保留句首的数字
+func splitSentences(text string) []string {
+	out := make([]string, 0)
+	var b strings.Builder
+	flush := func() {
+		s := strings.TrimSpace(listMarkerRe.ReplaceAllString(b.String(), ""))
+		if s != "" {
+			out = append(out, s)
+		}
+		b.Reset()
+	}
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		b.WriteRune(r)
+		switch r {
+		case '。', '！', '？', '；', '!', '?':
+			flush()
+		case '.':
+			// 英文句号后须跟空白或结尾，避免切开小数与版本号；“1. ”是有序列表序号
+			if (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) && !orderedRe.MatchString(b.String()) {
+				flush()
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+func unitSet(us []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(us))
+	for _, u := range us {
+		set[u] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"中英文句末", "第一句。第二句！Third one. Fourth?", []string{"第一句。", "第二句！", "Third one.", "Fourth?"}},
+		{"列表与标题", "## 步骤\n- 安装依赖\n* 启动服务\n> 引用内容", []string{"步骤", "安装依赖", "启动服务", "引用内容"}},
+		{"有序列表", "1. 打开配置\n2) 修改端口\n10. 重启", []string{"打开配置", "修改端口", "重启"}},
+		{"句首数字保留", "2024 年发布了 3 个版本\n3.14 是圆周率的近似值。", []string{"2024 年发布了 3 个版本", "3.14 是圆周率的近似值。"}},
+		{"小数与版本号不切开", "升级到 v1.2.3 后耗时降到 0.5 秒。", []string{"升级到 v1.2.3 后耗时降到 0.5 秒。"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := splitSentences(c.text); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+// 句首数字参与核对，不会因被当作列表序号去掉而漏掉无依据的数字
+func TestGroundLeadingNumber(t *testing.T) {
+	frags := []Fragment{{Text: "8080 端口是服务默认监听的端口"}}
+	g := Ground("8080 端口是服务默认监听的端口。\n9090 端口是服务默认监听的端口。", frags, 0.95)
+	if len(g.Claims) != 2 {
+		t.Fatalf("claims = %+v", g.Claims)
+	}
+	if !g.Claims[0].Supported || g.Claims[1].Supported {
+		t.Fatalf("claims = %+v", g.Claims)
+	}
+}
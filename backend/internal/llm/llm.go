@@ -0,0 +1,134 @@
+// Package llm 对话模型客户端：统一的 ChatClient 接口，按 llm.provider 适配 OpenAI 兼容接口与 Ollama
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"note-system/config"
+	"note-system/internal/httpx"
+	"note-system/internal/metrics"
+	"time"
+)
+
+// 支持的服务类型
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+)
+
+// HeaderOp 随请求发送 ChatRequest.Op 的请求头，网关与本地模拟接口据此区分用途
+const HeaderOp = "X-LLM-Op"
+
+// ErrDisabled 未配置 llm.url
+var ErrDisabled = errors.New("未配置 LLM 服务")
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest 一次对话请求
+type ChatRequest struct {
+	// Op 用途，作为指标与日志中的操作名，如 qa、summary
+	Op       string
+	Messages []Message
+	// MaxTokens 为 0 时使用 llm.max_tokens
+	MaxTokens int
+	// Temperature 为空时使用服务端默认值
+	Temperature *float64
+	// JSON 要求模型只输出 JSON 对象
+	JSON bool
+}
+
+// Usage token 用量，服务端未返回时为 0
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse 对话结果
+type ChatResponse struct {
+	Content string `json:"content"`
+	// FinishReason 结束原因：stop 正常结束，length 达到 token 上限
+	FinishReason string `json:"finish_reason"`
+	Model        string `json:"model"`
+	Usage        Usage  `json:"usage"`
+}
+
+// ChatClient 对话模型客户端
+type ChatClient interface {
+	// Chat 发送一次非流式对话；未配置服务时返回 ErrDisabled
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// Enabled 是否配置了对话服务
+	Enabled() bool
+}
+
+// adapter 把统一请求转换为具体服务的协议
+type adapter interface {
+	chat(ctx context.Context, hc *httpx.Client, cfg config.LLMConfig, req ChatRequest) (*ChatResponse, error)
+}
+
+var adapters = map[string]adapter{
+	ProviderOpenAI: openAI{},
+	ProviderOllama: ollama{},
+}
+
+// llmHTTP 对话接口共用的客户端；生成耗时长、成本高，只重试一次，熔断状态跨调用方共享
+var llmHTTP = httpx.New(httpx.Options{Name: "llm", MaxRetries: 1})
+
+type client struct {
+	cfg *config.Holder
+}
+
+// NewClient 每次调用时从 cfg 读取 llm 配置，热加载后立即生效
+func NewClient(cfg *config.Holder) ChatClient {
+	return &client{cfg: cfg}
+}
+
+func (c *client) Enabled() bool {
+	return c.cfg.Get().LLM.URL != ""
+}
+
+func (c *client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	cfg := c.cfg.Get().LLM
+	if cfg.URL == "" {
+		return nil, ErrDisabled
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = cfg.MaxTokens
+	}
+	if req.Op == "" {
+		req.Op = "chat"
+	}
+	a, ok := adapters[cfg.Provider]
+	if !ok {
+		a = adapters[ProviderOpenAI]
+	}
+	resp, err := a.chat(ctx, llmHTTP, cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	metrics.LLMTokens.WithLabelValues("prompt").Add(float64(resp.Usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues("completion").Add(float64(resp.Usage.CompletionTokens))
+	return resp, nil
+}
+
+func timeout(cfg config.LLMConfig) time.Duration {
+	return time.Duration(cfg.TimeoutSeconds) * time.Second
+}
+
+// opHeader 携带操作名的请求头
+func opHeader(op string) http.Header {
+	h := http.Header{}
+	h.Set(HeaderOp, op)
+	return h
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"note-system/config"
+	"note-system/internal/httpx"
+)
+
+// ollama Ollama 原生的 /api/chat 接口；llm.url 配置为完整地址，如 http://localhost:11434/api/chat
+type ollama struct{}
+
+type ollamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Format   string        `json:"format,omitempty"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaResponse struct {
+	Model   string  `json:"model"`
+	Message Message `json:"message"`
+	// DoneReason stop 或 length，与 OpenAI 的 finish_reason 含义一致
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func (ollama) chat(ctx context.Context, hc *httpx.Client, cfg config.LLMConfig, req ChatRequest) (*ChatResponse, error) {
+	in := ollamaRequest{
+		Model:    cfg.Model,
+		Messages: req.Messages,
+		Options:  ollamaOptions{NumPredict: req.MaxTokens, Temperature: req.Temperature},
+	}
+	if req.JSON {
+		in.Format = "json"
+	}
+	var out ollamaResponse
+	hreq := httpx.Request{Op: req.Op, Method: http.MethodPost, URL: cfg.URL, Header: opHeader(req.Op), Timeout: timeout(cfg)}
+	if err := hc.JSON(ctx, hreq, in, &out); err != nil {
+		return nil, err
+	}
+	model := out.Model
+	if model == "" {
+		model = cfg.Model
+	}
+	return &ChatResponse{
+		Content:      out.Message.Content,
+		FinishReason: out.DoneReason,
+		Model:        model,
+		Usage: Usage{
+			PromptTokens:     out.PromptEvalCount,
+			CompletionTokens: out.EvalCount,
+			TotalTokens:      out.PromptEvalCount + out.EvalCount,
+		},
+	}, nil
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"note-system/config"
+	"note-system/internal/httpx"
+)
+
+// openAI OpenAI 兼容的 chat/completions 接口（LM Studio、vLLM、llama.cpp server 等）；
+// llm.url 配置为完整地址，如 http://localhost:1234/v1/chat/completions
+type openAI struct{}
+
+type openAIRequest struct {
+	Model          string            `json:"model"`
+	Messages       []Message         `json:"messages"`
+	MaxTokens      int               `json:"max_tokens,omitempty"`
+	Temperature    *float64          `json:"temperature,omitempty"`
+	ResponseFormat map[string]string `json:"response_format,omitempty"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		// Text 旧版 completions 接口的返回字段
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+func (openAI) chat(ctx context.Context, hc *httpx.Client, cfg config.LLMConfig, req ChatRequest) (*ChatResponse, error) {
+	in := openAIRequest{Model: cfg.Model, Messages: req.Messages, MaxTokens: req.MaxTokens, Temperature: req.Temperature}
+	if req.JSON {
+		in.ResponseFormat = map[string]string{"type": "json_object"}
+	}
+	var out openAIResponse
+	hreq := httpx.Request{Op: req.Op, Method: http.MethodPost, URL: cfg.URL, Header: opHeader(req.Op), Timeout: timeout(cfg)}
+	if err := hc.JSON(ctx, hreq, in, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("LLM 未返回内容")
+	}
+	choice := out.Choices[0]
+	content := choice.Message.Content
+	if content == "" {
+		content = choice.Text
+	}
+	model := out.Model
+	if model == "" {
+		model = cfg.Model
+	}
+	return &ChatResponse{Content: content, FinishReason: choice.FinishReason, Model: model, Usage: out.Usage}, nil
+}
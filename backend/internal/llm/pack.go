@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// fragmentOverhead 每条片段在提示词中的编号、换行等固定开销
+	fragmentOverhead = 6
+	// minTrimTokens 剩余预算不足该值时不再截断片段塞入，截得太短的片段价值不大
+	minTrimTokens = 48
+	// nearDuplicate 两条片段字符二元组的 Jaccard 相似度达到该值时视为重复
+	nearDuplicate = 0.85
+)
+
+// Fragment 待放入提示词的一条检索片段
+type Fragment struct {
+	// Ref 调用方自定义的编号，用于把结果对应回原始检索结果
+	Ref   int
+	Label string
+	Text  string
+	Score float64
+	// Trimmed 是否因预算不足被截断
+	Trimmed bool
+}
+
+// Pack 按相关度从高到低挑选片段放入 limit 个 token 之内：先去掉与更相关片段重复或被其包含的片段，
+// 放不下的片段在剩余预算足够时截断放入，其余丢弃。limit < 0 表示不限制长度
+func Pack(tok Tokenizer, limit int, frags []Fragment) []Fragment {
+	sorted := make([]Fragment, len(frags))
+	copy(sorted, frags)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	kept := dedupe(sorted)
+	if limit < 0 {
+		return kept
+	}
+	out := make([]Fragment, 0, len(kept))
+	used := 0
+	for _, f := range kept {
+		cost := fragmentOverhead + tok.Count(f.Label) + tok.Count(f.Text)
+		if used+cost <= limit {
+			out = append(out, f)
+			used += cost
+			continue
+		}
+		// 相关度更低的片段即使更短也不再放入，保证结果按相关度连续
+		remain := limit - used - fragmentOverhead - tok.Count(f.Label)
+		if remain >= minTrimTokens {
+			f.Text = trimToTokens(tok, f.Text, remain)
+			f.Trimmed = true
+			out = append(out, f)
+		}
+		break
+	}
+	return out
+}
+
+// dedupe 保留顺序，去掉与前面片段相同、被其包含或高度相似的片段；
+// 后面的片段包含前面的片段时，用更完整的内容替换前者，相关度沿用前者
+func dedupe(frags []Fragment) []Fragment {
+	out := make([]Fragment, 0, len(frags))
+	norms := make([]string, 0, len(frags))
+	grams := make([]map[string]struct{}, 0, len(frags))
+next:
+	for _, f := range frags {
+		norm := strings.Join(strings.Fields(f.Text), " ")
+		if norm == "" {
+			continue
+		}
+		g := bigrams(norm)
+		for i, kept := range norms {
+			switch {
+			case strings.Contains(kept, norm):
+				continue next
+			case strings.Contains(norm, kept):
+				f.Score = out[i].Score
+				out[i], norms[i], grams[i] = f, norm, g
+				continue next
+			case jaccard(g, grams[i]) >= nearDuplicate:
+				continue next
+			}
+		}
+		out = append(out, f)
+		norms = append(norms, norm)
+		grams = append(grams, g)
+	}
+	return out
+}
+
+func bigrams(s string) map[string]struct{} {
+	runes := []rune(s)
+	set := make(map[string]struct{}, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		set[string(runes[i:i+2])] = struct{}{}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// trimToTokens 截取 text 开头不超过 limit 个 token 的部分，尽量在句末断开，以省略号结尾
+func trimToTokens(tok Tokenizer, text string, limit int) string {
+	runes := []rune(text)
+	// 二分查找能放下的最长前缀（省略号计 1 个 token）
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if tok.Count(string(runes[:mid]))+1 <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	cut := string(runes[:lo])
+	// 后半段内有句末标点时在其后断开
+	if i := strings.LastIndexAny(cut, "。！？.!?\n"); i >= 0 && utf8.RuneCountInString(cut[:i]) >= lo/2 {
+		_, size := utf8.DecodeRuneInString(cut[i:])
+		cut = cut[:i+size]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
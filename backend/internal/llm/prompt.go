@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// 内置提示词模板，文件名即用途
+const (
+	PromptQA      = "qa"
+	PromptSummary = "summary"
+	PromptTagging = "tagging"
+	PromptRewrite = "rewrite"
+	PromptHyDE    = "hyde"
+)
+
+// 每个模板文件定义 system 与 user 两个子模板，分别渲染为两条消息；system 渲染为空时省略
+//
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+var funcs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}
+
+// builtin 每个文件单独解析，避免各文件的 system、user 定义互相覆盖
+var builtin = map[string]*template.Template{}
+
+func init() {
+	for _, name := range []string{PromptQA, PromptSummary, PromptTagging, PromptRewrite, PromptHyDE} {
+		file := name + ".tmpl"
+		builtin[file] = template.Must(template.New(file).Funcs(funcs).ParseFS(builtinFS, "templates/"+file))
+	}
+}
+
+// Render 渲染 name 对应的提示词。dir 非空且存在 <dir>/<name>.tmpl 时使用该文件，
+// 每次调用都重新读取，修改模板无需重启；否则使用内置模板
+func Render(dir, name string, data interface{}) ([]Message, error) {
+	t, err := lookup(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	system, err := execute(t, "system", data)
+	if err != nil {
+		return nil, err
+	}
+	user, err := execute(t, "user", data)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []Message
+	if system != "" {
+		msgs = append(msgs, Message{Role: RoleSystem, Content: system})
+	}
+	return append(msgs, Message{Role: RoleUser, Content: user}), nil
+}
+
+func lookup(dir, name string) (*template.Template, error) {
+	file := name + ".tmpl"
+	if dir != "" {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		switch {
+		case err == nil:
+			t, err := template.New(file).Funcs(funcs).Parse(string(b))
+			if err != nil {
+				return nil, fmt.Errorf("解析提示词模板 %s 失败: %w", file, err)
+			}
+			return t, nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("读取提示词模板 %s 失败: %w", file, err)
+		}
+	}
+	if t, ok := builtin[file]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("提示词模板 %s 不存在", file)
+}
+
+func execute(t *template.Template, name string, data interface{}) (string, error) {
+	if t.Lookup(name) == nil {
+		return "", fmt.Errorf("提示词模板 %s 缺少 %q 定义", t.Name(), name)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("渲染提示词模板 %s 失败: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// QAData qa 模板的数据
+type QAData struct {
+	Question string
+	Contexts []QAContext
+}
+
+// QAContext 检索到的一条片段，Label 为来源说明（笔记标题、附件名与页码等），可为空
+type QAContext struct {
+	Label string
+	Text  string
+}
+
+// NoteData summary 与 tagging 模板的数据
+type NoteData struct {
+	Title   string
+	Content string
+	// MaxTags 标签数上限，仅 tagging 使用
+	MaxTags int
+}
+
+// QueryData rewrite 与 hyde 模板的数据
+type QueryData struct {
+	Question string
+	// History 之前的对话，按时间先后排列，仅 rewrite 使用
+	History []Message
+	// Variants 额外生成的问法数量，仅 rewrite 使用
+	Variants int
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer 统计文本的 token 数。默认使用 Estimator 估算，接入模型自带的分词器时实现该接口即可
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// TokenizerFunc 函数形式的 Tokenizer
+type TokenizerFunc func(text string) int
+
+func (f TokenizerFunc) Count(text string) int { return f(text) }
+
+// messageOverhead 每条消息的角色标记等固定开销
+const messageOverhead = 4
+
+// Estimator 不依赖具体模型的估算：中日韩文字每字计 1，连续的字母数字每 4 字节计 1（不足按 1），
+// 标点与其他符号各计 1，空白不计。主流分词器下通常略高于实际值，用于预算时偏安全
+type Estimator struct{}
+
+func (Estimator) Count(text string) int {
+	n, word := 0, 0
+	flush := func() {
+		n += (word + 3) / 4
+		word = 0
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			n++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word += utf8.RuneLen(r)
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			n++
+		}
+	}
+	flush()
+	return n
+}
+
+// CountMessages 一组消息的 token 数，含每条消息的固定开销
+func CountMessages(tok Tokenizer, msgs []Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += messageOverhead + tok.Count(m.Content)
+	}
+	return n
+}
+
+// Budget 提示词的 token 预算：上下文窗口扣除为回答预留的部分
+type Budget struct {
+	Tokenizer Tokenizer
+	// Context 模型的上下文窗口大小
+	Context int
+	// Answer 为回答预留的 token 数，与请求中的 max_tokens 一致
+	Answer int
+}
+
+// Prompt 提示词可用的 token 数
+func (b Budget) Prompt() int {
+	return b.Context - b.Answer
+}
+
+// Fits 消息是否在预算之内
+func (b Budget) Fits(msgs []Message) bool {
+	return CountMessages(b.Tokenizer, msgs) <= b.Prompt()
+}
@@ -0,0 +1,82 @@
+// Package logging 基于 log/slog 的结构化日志：请求ID随 context 传递，
+// 使用 slog.XxxContext 记录的日志会自动带上 request_id
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// HeaderRequestID 请求ID的 HTTP 头，客户端传入时沿用，否则生成新的
+const HeaderRequestID = "X-Request-ID"
+
+// Setup 按配置初始化默认 logger，format 为 json 或 text，level 为 debug/info/warn/error
+func Setup(w io.Writer, level, format string) {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		lv = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	var h slog.Handler
+	if strings.EqualFold(format, "json") {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+// WithRequestID 把请求ID放入 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID 取出 context 中的请求ID，没有时返回空串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// NewRequestID 生成 16 位十六进制的请求ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WarnIf err 不为空时记录警告，用于“失败不影响主流程”的外部同步（ES、向量库、链接等）
+func WarnIf(ctx context.Context, err error, msg string, args ...interface{}) {
+	if err == nil {
+		return
+	}
+	slog.WarnContext(ctx, msg, append(args, "err", err)...)
+}
+
+// contextHandler 在每条日志上附加 context 中的请求ID
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,130 @@
+// Package metrics Prometheus 指标定义，统一以 note_ 为前缀，由 /metrics 暴露
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry 本服务的指标注册表，不使用全局默认注册表以免混入第三方库的指标
+var Registry = prometheus.NewRegistry()
+
+// 外部调用耗时较长（LLM 可达数十秒），桶上限放宽到 60s
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "note_http_request_duration_seconds",
+		Help:    "HTTP 请求耗时，route 为路由模板",
+		Buckets: latencyBuckets,
+	}, []string{"method", "route", "status"})
+
+	externalDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "note_external_request_duration_seconds",
+		Help:    "外部服务调用耗时（含重试）",
+		Buckets: latencyBuckets,
+	}, []string{"service", "op"})
+
+	externalTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "note_external_requests_total",
+		Help: "外部服务调用次数，result 为 ok/error/circuit_open",
+	}, []string{"service", "op", "result"})
+
+	externalRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "note_external_retries_total",
+		Help: "外部服务调用的重试次数",
+	}, []string{"service", "op"})
+
+	// EmbeddedTexts 送入向量化的文本条数
+	EmbeddedTexts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "note_embedded_texts_total",
+		Help: "送入向量化的文本条数",
+	})
+
+	// UpsertedVectors 写入向量库的向量条数
+	UpsertedVectors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "note_vectors_upserted_total",
+		Help: "写入向量库的向量条数",
+	})
+
+	// LLMTokens LLM 消耗的 token 数，kind 为 prompt/completion
+	LLMTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "note_llm_tokens_total",
+		Help: "LLM 消耗的 token 数",
+	}, []string{"kind"})
+
+	// IndexingInflight 正在进行的索引任务数（切分、向量化、写入向量库）
+	IndexingInflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "note_indexing_inflight",
+		Help: "正在进行的索引任务数",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpDuration, externalDuration, externalTotal, externalRetries,
+		EmbeddedTexts, UpsertedVectors, LLMTokens, IndexingInflight,
+	)
+}
+
+// Middleware 按路由模板记录请求耗时，未匹配的路由统一记为 unmatched，避免标签基数爆炸
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler /metrics 接口
+func Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+	return gin.WrapH(h)
+}
+
+// ObserveExternal 记录一次外部调用，result 为 ok/error/circuit_open
+func ObserveExternal(service, op, result string, d time.Duration, retries int) {
+	externalDuration.WithLabelValues(service, op).Observe(d.Seconds())
+	externalTotal.WithLabelValues(service, op, result).Inc()
+	if retries > 0 {
+		externalRetries.WithLabelValues(service, op).Add(float64(retries))
+	}
+}
+
+// RegisterCounts 注册抓取时才计算的计数类 gauge（如各来源的片段数），fn 出错时本次不输出
+func RegisterCounts(name, help, label string, fn func() (map[string]int64, error)) {
+	Registry.MustRegister(&countCollector{
+		desc: prometheus.NewDesc(name, help, []string{label}, nil),
+		fn:   fn,
+	})
+}
+
+type countCollector struct {
+	desc *prometheus.Desc
+	fn   func() (map[string]int64, error)
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.fn()
+	if err != nil {
+		return
+	}
+	for k, v := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(v), k)
+	}
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Blob 按内容哈希去重后的文件实体，RefCount 为引用它的附件数
+type Blob struct {
+	Hash      string    `gorm:"primaryKey;type:varchar(64)" json:"hash"`
+	Size      int64     `gorm:"not null" json:"size"`
+	MIME      string    `gorm:"size:128" json:"mime"`
+	RefCount  int64     `gorm:"not null;default:0" json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Blob) TableName() string {
+	return "blobs"
+}
+
+// Attachment 笔记附件，多个附件可指向同一个 Blob
+type Attachment struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	NoteID    int64     `gorm:"not null;index" json:"note_id"`
+	BlobHash  string    `gorm:"type:varchar(64);not null;index" json:"hash"`
+	FileName  string    `gorm:"size:255" json:"file_name"`
+	MIME      string    `gorm:"size:128" json:"mime"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
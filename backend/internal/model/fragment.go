@@ -2,13 +2,25 @@ package model
 
 import "time"
 
+// 片段来源
+const (
+	FragSourceNote       = "note"
+	FragSourceAttachment = "attachment"
+)
+
 type Fragment struct {
-	ID        int64     `gorm:"primaryKey" json:"id"`
-	NoteID    int64     `json:"note_id" index:"idx_note_id"`
-	FragID    string    `gorm:"type:varchar(64);uniqueIndex" json:"frag_id"`
-	Content   string    `gorm:"type:longtext" json:"content"`
-	IsCode    bool      `json:"is_code"`
-	VectorID  string    `gorm:"type:varchar(128)" json:"vector_id"`
+	ID       int64  `gorm:"primaryKey" json:"id"`
+	NoteID   int64  `json:"note_id" index:"idx_note_id"`
+	FragID   string `gorm:"type:varchar(64);uniqueIndex" json:"frag_id"`
+	Content  string `gorm:"type:longtext" json:"content"`
+	IsCode   bool   `json:"is_code"`
+	VectorID string `gorm:"type:varchar(128)" json:"vector_id"`
+	// Source 片段来源：note 为笔记正文，attachment 为附件抽取的文本
+	Source string `gorm:"size:16;not null;default:'note'" json:"source"`
+	// AttachmentID 来源附件，正文片段为 0
+	AttachmentID int64 `gorm:"not null;default:0;index" json:"attachment_id"`
+	// Page 附件中的页码（从 1 开始），无分页时为 0
+	Page      int       `gorm:"not null;default:0" json:"page"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
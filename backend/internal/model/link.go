@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// 链接写法
+const (
+	LinkKindTitle = "title" // [[笔记标题]]
+	LinkKindID    = "id"    // [[id:123]]
+)
+
+// NoteLink 笔记之间的 wiki 链接，由源笔记内容解析得到
+type NoteLink struct {
+	ID int64 `gorm:"primaryKey" json:"id"`
+	// SourceID 包含链接的笔记
+	SourceID int64 `gorm:"not null;index" json:"source_id"`
+	// TargetID 链接指向的笔记，标题链接无法解析时为 0
+	TargetID int64 `gorm:"not null;default:0;index" json:"target_id"`
+	// TargetTitle 链接中书写的标题（id 链接时为目标笔记当前标题）
+	TargetTitle string `gorm:"size:200" json:"target_title"`
+	Kind        string `gorm:"size:16;not null" json:"kind"`
+	// Dangling 目标不存在或已删除
+	Dangling  bool      `gorm:"not null;default:false" json:"dangling"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (NoteLink) TableName() string {
+	return "note_links"
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// NoteMerge 一次笔记合并的记录，保存合并前目标笔记与各被合并笔记的完整内容，便于追溯与手动恢复
+type NoteMerge struct {
+	ID int64 `gorm:"primaryKey" json:"id"`
+	// TargetID 保留下来的笔记
+	TargetID int64 `gorm:"not null;index" json:"target_id"`
+	// TargetTitle、TargetContent 合并前目标笔记的标题与正文
+	TargetTitle   string `gorm:"size:200" json:"target_title"`
+	TargetContent string `gorm:"type:longtext" json:"target_content"`
+	// Sources 被合并的笔记，合并后移入回收站
+	Sources   []MergedNote `gorm:"serializer:json;type:longtext" json:"sources"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// MergedNote 被合并笔记在合并时的快照
+type MergedNote struct {
+	ID      int64    `json:"id"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+func (NoteMerge) TableName() string {
+	return "note_merges"
+}
@@ -1,6 +1,12 @@
 package model
 
-import "time"
+import (
+	"note-system/internal/textutil"
+	"time"
+)
+
+// ExcerptRunes 笔记摘要的最大字数
+const ExcerptRunes = 120
 
 // Note 代表一个笔记实体，映射数据库中的笔记表
 type Note struct {
@@ -16,6 +22,37 @@ type Note struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	// IsDeleted 软删除标记，0表示未删除，1表示已删除
 	IsDeleted int8 `gorm:"not null;default:0" json:"-"`
+	// SourceURL 剪藏笔记的原文地址，手写笔记为空
+	SourceURL string `gorm:"size:1024" json:"source_url,omitempty"`
+	// DeletedAt 移入回收站的时间，未删除时为空
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	// Excerpt 正文纯文本的前 ExcerptRunes 个字，写库时生成，供列表展示
+	Excerpt string `gorm:"size:512" json:"excerpt"`
+	// WordCount 字数，写库时统计
+	WordCount int `gorm:"not null;default:0" json:"word_count"`
+	// PurgeAt 回收站中笔记将被自动清除的时间，由服务层按保留期计算，不落库
+	PurgeAt *time.Time `gorm:"-" json:"purge_at,omitempty"`
+	// Summary LLM 生成的 1~3 句摘要，未生成时为空
+	Summary string `gorm:"type:text" json:"summary,omitempty"`
+	// SuggestedTitle LLM 建议的标题，不会自动替换原标题
+	SuggestedTitle string `gorm:"size:200" json:"suggested_title,omitempty"`
+	// SuggestedTags LLM 建议的标签，不会自动加到笔记上
+	SuggestedTags []string `gorm:"serializer:json;size:1024" json:"suggested_tags,omitempty"`
+	// SummarizedAt 摘要对应的笔记版本（生成时笔记的 UpdatedAt），早于 UpdatedAt 说明摘要已过期
+	SummarizedAt *time.Time `json:"summarized_at,omitempty"`
+	// Tags 笔记标签，仅在列表接口按需加载，不落库
+	Tags []string `gorm:"-" json:"tags,omitempty"`
+}
+
+// SummaryFresh LLM 摘要是否对应当前版本的正文
+func (n *Note) SummaryFresh() bool {
+	return n.SummarizedAt != nil && !n.SummarizedAt.Before(n.UpdatedAt)
+}
+
+// Summarize 根据正文重新生成摘要与字数，正文变化后写库前调用
+func (n *Note) Summarize() {
+	n.Excerpt = textutil.Excerpt(n.Content, ExcerptRunes)
+	n.WordCount = textutil.WordCount(n.Content)
 }
 
 func (Note) TableName() string {
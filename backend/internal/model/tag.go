@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// NoteTag 笔记标签，一条记录对应笔记上的一个标签
+type NoteTag struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	NoteID    int64     `gorm:"not null;uniqueIndex:uk_note_tag" json:"note_id"`
+	Tag       string    `gorm:"size:64;not null;uniqueIndex:uk_note_tag" json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (NoteTag) TableName() string {
+	return "note_tags"
+}
@@ -0,0 +1,68 @@
+package rag
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryIndex 进程内的向量索引，按余弦相似度暴力检索，返回结构与 Pinecone 查询一致；
+// 用于离线评测等不便连接 Pinecone 的场景，数据量在数万条以内时足够快
+type MemoryIndex struct {
+	mu      sync.RWMutex
+	vectors map[string]memoryVector
+}
+
+type memoryVector struct {
+	values   []float32
+	metadata map[string]interface{}
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{vectors: make(map[string]memoryVector)}
+}
+
+// Upsert 写入或覆盖一条向量，向量在写入时归一化
+func (m *MemoryIndex) Upsert(id string, values []float32, metadata map[string]interface{}) {
+	v := make([]float32, len(values))
+	copy(v, values)
+	normalize(v)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vectors[id] = memoryVector{values: v, metadata: metadata}
+}
+
+func (m *MemoryIndex) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.vectors)
+}
+
+// Query 返回与 vec 最相似的 topK 条，分数相同时按 ID 排序保证结果稳定
+func (m *MemoryIndex) Query(vec []float32, topK int) *QueryResp {
+	q := make([]float32, len(vec))
+	copy(q, vec)
+	normalize(q)
+	m.mu.RLock()
+	matches := make([]Match, 0, len(m.vectors))
+	for id, v := range m.vectors {
+		if len(v.values) != len(q) {
+			continue
+		}
+		var dot float32
+		for i := range q {
+			dot += q[i] * v.values[i]
+		}
+		matches = append(matches, Match{ID: id, Score: dot, Metadata: v.metadata})
+	}
+	m.mu.RUnlock()
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return &QueryResp{Matches: matches}
+}
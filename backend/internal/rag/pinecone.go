@@ -1,50 +1,54 @@
 package rag
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
-	"os"
+	"net/url"
+	"note-system/config"
+	"note-system/internal/httpx"
+	"note-system/internal/metrics"
+	"sort"
+	"time"
 )
 
+// pineconeHTTP 所有 Pinecone 调用共用，熔断状态跨请求保持
+var pineconeHTTP = httpx.New(httpx.Options{Name: "pinecone"})
+
+type upsertVector struct {
+	ID       string      `json:"id"`
+	Values   []float32   `json:"values"`
+	Metadata interface{} `json:"metadata"`
+}
+
 type upsertReq struct {
-	Vectors []struct {
-		ID       string      `json:"id"`
-		Values   []float32   `json:"values"`
-		Metadata interface{} `json:"metadata"`
-	} `json:"vectors"`
+	Vectors []upsertVector `json:"vectors"`
+}
+
+// pineconeFetchBatch fetch 接口的 ID 放在查询串中，分批请求避免 URL 过长
+const pineconeFetchBatch = 100
+
+func pineconeCall(ctx context.Context, cfg config.RagConfig, op, path string, in, out interface{}) error {
+	return pineconeDo(ctx, cfg, op, http.MethodPost, path, in, out)
+}
+
+func pineconeDo(ctx context.Context, cfg config.RagConfig, op, method, path string, in, out interface{}) error {
+	req := httpx.Request{Op: op, Method: method, URL: cfg.PineconeHost + path, Header: http.Header{}, Timeout: time.Duration(cfg.PineconeTimeoutSeconds) * time.Second}
+	req.Header.Set("Api-Key", cfg.PineconeAPIKey)
+	return pineconeHTTP.JSON(ctx, req, in, out)
 }
 
-func PineconeUpsert(index string, vectors map[string][]float32, meta map[string]map[string]interface{}) error {
-	apiKey := os.Getenv("PINECONE_API_KEY")
-	host := os.Getenv("PINECONE_HOST")
-	if apiKey == "" || host == "" {
+func PineconeUpsert(ctx context.Context, cfg config.RagConfig, vectors map[string][]float32, meta map[string]map[string]interface{}) error {
+	if cfg.PineconeAPIKey == "" || cfg.PineconeHost == "" || len(vectors) == 0 {
 		return nil
 	}
-	endpoint := fmt.Sprintf("%s/vectors/upsert", host)
-	b := upsertReq{Vectors: make([]struct {
-		ID       string      "json:\"id\""
-		Values   []float32   "json:\"values\""
-		Metadata interface{} "json:\"metadata\""
-	}, 0, len(vectors))}
+	b := upsertReq{Vectors: make([]upsertVector, 0, len(vectors))}
 	for id, vec := range vectors {
-		item := struct {
-			ID       string      `json:"id"`
-			Values   []float32   `json:"values"`
-			Metadata interface{} `json:"metadata"`
-		}{ID: id, Values: vec, Metadata: meta[id]}
-		b.Vectors = append(b.Vectors, item)
+		b.Vectors = append(b.Vectors, upsertVector{ID: id, Values: vec, Metadata: meta[id]})
 	}
-	payload, _ := json.Marshal(b)
-	req, _ := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Api-Key", apiKey)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	if err := pineconeCall(ctx, cfg, "upsert", "/vectors/upsert", b, nil); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	metrics.UpsertedVectors.Add(float64(len(b.Vectors)))
 	return nil
 }
 
@@ -54,73 +58,134 @@ type QueryReq struct {
 	Namespace       string      `json:"namespace,omitempty"`
 	Filter          interface{} `json:"filter,omitempty"`
 	IncludeMetadata bool        `json:"includeMetadata"`
+	IncludeValues   bool        `json:"includeValues,omitempty"`
+}
+
+type Match struct {
+	ID       string                 `json:"id"`
+	Score    float32                `json:"score"`
+	Metadata map[string]interface{} `json:"metadata"`
+	// Values 只在请求 IncludeValues 时返回
+	Values []float32 `json:"values,omitempty"`
 }
 
 type QueryResp struct {
-	Matches []struct {
-		ID       string                 `json:"id"`
-		Score    float32                `json:"score"`
-		Metadata map[string]interface{} `json:"metadata"`
-	} `json:"matches"`
+	Matches []Match `json:"matches"`
 }
 
-func PineconeQueryTopK(vec []float32, topK int) (*QueryResp, error) {
-	host := os.Getenv("PINECONE_HOST")
-	apiKey := os.Getenv("PINECONE_API_KEY")
-	if host == "" || apiKey == "" || len(vec) == 0 {
-		return nil, nil
+// MergeMatches 合并多次检索的结果：同一向量只保留一次并取最高分，按分数从高到低排列
+func MergeMatches(results ...*QueryResp) []Match {
+	index := make(map[string]int)
+	out := make([]Match, 0)
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		for _, m := range res.Matches {
+			if i, ok := index[m.ID]; ok {
+				if m.Score > out[i].Score {
+					out[i].Score = m.Score
+				}
+				continue
+			}
+			index[m.ID] = len(out)
+			out = append(out, m)
+		}
 	}
-	reqBody := QueryReq{TopK: topK, Vector: vec, IncludeMetadata: true}
-	b, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("POST", host+"/query", bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Api-Key", apiKey)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+func PineconeQueryTopK(ctx context.Context, cfg config.RagConfig, vec []float32, topK int) (*QueryResp, error) {
+	return PineconeQuery(ctx, cfg, QueryReq{TopK: topK, Vector: vec, IncludeMetadata: true})
+}
+
+// PineconeQuery 按完整的查询条件检索，可附带元数据过滤与返回向量值
+func PineconeQuery(ctx context.Context, cfg config.RagConfig, q QueryReq) (*QueryResp, error) {
+	if cfg.PineconeHost == "" || cfg.PineconeAPIKey == "" || len(q.Vector) == 0 {
+		return nil, nil
 	}
-	defer resp.Body.Close()
 	var out QueryResp
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := pineconeCall(ctx, cfg, "query", "/query", q, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
-func PineconeDeleteAll() error {
-	host := os.Getenv("PINECONE_HOST")
-	apiKey := os.Getenv("PINECONE_API_KEY")
-	if host == "" || apiKey == "" {
+// PineconeFetch 按ID取回已写入的向量值，不存在的ID不出现在结果中
+func PineconeFetch(ctx context.Context, cfg config.RagConfig, ids []string) (map[string][]float32, error) {
+	out := make(map[string][]float32, len(ids))
+	if !PineconeEnabled(cfg) {
+		return out, nil
+	}
+	for start := 0; start < len(ids); start += pineconeFetchBatch {
+		end := min(start+pineconeFetchBatch, len(ids))
+		q := url.Values{}
+		for _, id := range ids[start:end] {
+			q.Add("ids", id)
+		}
+		var resp struct {
+			Vectors map[string]struct {
+				Values []float32 `json:"values"`
+			} `json:"vectors"`
+		}
+		if err := pineconeDo(ctx, cfg, "fetch", http.MethodGet, "/vectors/fetch?"+q.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		for id, v := range resp.Vectors {
+			out[id] = v.Values
+		}
+	}
+	return out, nil
+}
+
+func PineconeDeleteAll(ctx context.Context, cfg config.RagConfig) error {
+	if cfg.PineconeHost == "" || cfg.PineconeAPIKey == "" {
 		return nil
 	}
-	body := map[string]interface{}{"deleteAll": true}
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequest("POST", host+"/vectors/delete", bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Api-Key", apiKey)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	return pineconeCall(ctx, cfg, "delete", "/vectors/delete", map[string]interface{}{"deleteAll": true}, nil)
+}
+
+func PineconeDeleteByIDs(ctx context.Context, cfg config.RagConfig, ids []string) error {
+	if cfg.PineconeHost == "" || cfg.PineconeAPIKey == "" || len(ids) == 0 {
+		return nil
 	}
-	defer resp.Body.Close()
-	return nil
+	return pineconeCall(ctx, cfg, "delete", "/vectors/delete", map[string]interface{}{"ids": ids}, nil)
 }
 
-func PineconeDeleteByIDs(ids []string) error {
-	host := os.Getenv("PINECONE_HOST")
-	apiKey := os.Getenv("PINECONE_API_KEY")
-	if host == "" || apiKey == "" || len(ids) == 0 {
+// PineconeSetMetadata 合并更新向量的元数据，不重新生成向量
+func PineconeSetMetadata(ctx context.Context, cfg config.RagConfig, ids []string, meta map[string]interface{}) error {
+	if !PineconeEnabled(cfg) {
 		return nil
 	}
-	body := map[string]interface{}{"ids": ids}
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequest("POST", host+"/vectors/delete", bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Api-Key", apiKey)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	// update 接口一次只能更新一个向量
+	for _, id := range ids {
+		if err := pineconeCall(ctx, cfg, "update", "/vectors/update", map[string]interface{}{"id": id, "setMetadata": meta}, nil); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
 	return nil
 }
+
+// IndexStats Pinecone 索引统计
+type IndexStats struct {
+	Dimension        int   `json:"dimension"`
+	TotalVectorCount int64 `json:"totalVectorCount"`
+}
+
+// PineconeEnabled 是否配置了 Pinecone，未配置时写入与查询均为空操作
+func PineconeEnabled(cfg config.RagConfig) bool {
+	return cfg.PineconeHost != "" && cfg.PineconeAPIKey != ""
+}
+
+// PineconeStats 查询索引的维度与向量总数，用于健康检查与数量核对
+func PineconeStats(ctx context.Context, cfg config.RagConfig) (*IndexStats, error) {
+	if !PineconeEnabled(cfg) {
+		return nil, nil
+	}
+	var out IndexStats
+	if err := pineconeCall(ctx, cfg, "stats", "/describe_index_stats", map[string]interface{}{}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -1,14 +1,22 @@
 package rag
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/binary"
-	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"net/http"
-	"os"
+	"note-system/config"
+	"note-system/internal/httpx"
+	"note-system/internal/metrics"
+	"note-system/internal/textutil"
+	"time"
 )
 
+// teiHTTP 所有向量化调用共用，熔断后保存笔记不再被拖慢
+var teiHTTP = httpx.New(httpx.Options{Name: "embedding"})
+
 type teiReq struct {
 	Inputs []string `json:"inputs"`
 }
@@ -16,37 +24,60 @@ type teiResp struct {
 	Embeddings [][]float32 `json:"embeddings"`
 }
 
-func EmbedBatch(texts []string) ([][]float32, error) {
-	url := os.Getenv("EMBEDDING_URL")
+func EmbedBatch(ctx context.Context, cfg config.RagConfig, texts []string) ([][]float32, error) {
+	url := cfg.EmbeddingURL
 	if url == "" {
-		dim := 1024
-		if v := os.Getenv("EMBED_DIM"); v != "" {
-			// simple parse without errors fallback
-			if n := atoi(v); n > 0 {
-				dim = n
-			}
+		dim := cfg.EmbedDim
+		if dim <= 0 {
+			dim = 1024
 		}
 		out := make([][]float32, len(texts))
 		for i, t := range texts {
 			out[i] = localEmbed(t, dim)
 		}
+		metrics.EmbeddedTexts.Add(float64(len(texts)))
 		return out, nil
 	}
-	req := teiReq{Inputs: texts}
-	b, _ := json.Marshal(req)
-	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 	var r teiResp
-	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+	req := httpx.Request{Op: "embed", Method: http.MethodPost, URL: url, Timeout: time.Duration(cfg.EmbeddingTimeoutSeconds) * time.Second}
+	if err := teiHTTP.JSON(ctx, req, teiReq{Inputs: texts}, &r); err != nil {
 		return nil, err
 	}
+	if len(r.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding: 返回 %d 个向量，期望 %d 个", len(r.Embeddings), len(texts))
+	}
+	metrics.EmbeddedTexts.Add(float64(len(texts)))
 	return r.Embeddings, nil
 }
 
+// localEmbed 未配置向量服务时的本地向量：把 textutil.Terms 切出的词元按哈希映射到各维并累加，
+// 词元重合越多余弦相似度越高，离线时也能做基于字面的检索，cmd/rageval 默认即评测这一实现。
+// 没有词元时退回按全文哈希的伪随机向量。
+// 早期版本的本地向量只按全文哈希生成，与现在的向量不可比较；升级后调用
+// POST /api/note/reindex 重建全部向量（修改本函数后同样需要重建）
 func localEmbed(text string, dim int) []float32 {
+	terms := textutil.Terms(text)
+	if len(terms) == 0 {
+		return seedEmbed(text, dim)
+	}
+	v := make([]float32, dim)
+	for _, t := range terms {
+		h := fnv.New64a()
+		h.Write([]byte(t))
+		x := h.Sum64()
+		// 低位决定维度，最高位决定符号，减少不同词元碰撞后相互叠加的偏差
+		if x>>63 == 1 {
+			v[x%uint64(dim)]--
+		} else {
+			v[x%uint64(dim)]++
+		}
+	}
+	normalize(v)
+	return v
+}
+
+// seedEmbed 以全文哈希为种子生成的伪随机单位向量，只有完全相同的文本才会相似
+func seedEmbed(text string, dim int) []float32 {
 	h := sha1.Sum([]byte(text))
 	seed := binary.BigEndian.Uint32(h[0:4])
 	// LCG parameters
@@ -60,30 +91,22 @@ func localEmbed(text string, dim int) []float32 {
 		// map to [-1,1]
 		v[i] = float32(int32(x)) / float32(int32(m))
 	}
-	// simple L2 normalization
+	normalize(v)
+	return v
+}
+
+// normalize L2 归一化
+func normalize(v []float32) {
 	var sum float64
-	for i := 0; i < dim; i++ {
+	for i := range v {
 		sum += float64(v[i] * v[i])
 	}
 	if sum > 0 {
 		inv := float32(1.0 / sqrt(sum))
-		for i := 0; i < dim; i++ {
+		for i := range v {
 			v[i] *= inv
 		}
 	}
-	return v
-}
-
-func atoi(s string) int {
-	n := 0
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-		if ch < '0' || ch > '9' {
-			break
-		}
-		n = n*10 + int(ch-'0')
-	}
-	return n
 }
 
 func sqrt(x float64) float64 {
@@ -0,0 +1,57 @@
+package rag
+
+import "strconv"
+
+// Centroid 各向量归一化后的平均方向，维度不一致的向量被跳过；没有可用向量时返回 nil
+func Centroid(vecs [][]float32) []float32 {
+	var out []float32
+	for _, v := range vecs {
+		if len(v) == 0 || (out != nil && len(v) != len(out)) {
+			continue
+		}
+		if out == nil {
+			out = make([]float32, len(v))
+		}
+		u := make([]float32, len(v))
+		copy(u, v)
+		normalize(u)
+		for i := range u {
+			out[i] += u[i]
+		}
+	}
+	if out != nil {
+		normalize(out)
+	}
+	return out
+}
+
+// Cosine 余弦相似度，维度不一致或存在零向量时为 0
+func Cosine(a, b []float32) float32 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / sqrt(na*nb))
+}
+
+// NoteID 片段元数据中所属笔记的ID，缺失或格式不对时返回 0
+func (m Match) NoteID() int64 {
+	switch t := m.Metadata["note_id"].(type) {
+	case int64:
+		return t
+	case float64:
+		return int64(t)
+	case string:
+		n, _ := strconv.ParseInt(t, 10, 64)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"note-system/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AttachmentRepository interface {
+	// Create 保存附件并将对应 Blob 的引用计数加一（Blob 不存在时创建）
+	Create(att *model.Attachment, blob *model.Blob) error
+	GetByID(id int64) (*model.Attachment, error)
+	ListByNote(noteID int64) ([]model.Attachment, error)
+	ListByNotes(noteIDs []int64) ([]model.Attachment, error)
+	// Delete 删除附件并将对应 Blob 的引用计数减一
+	Delete(id int64) error
+	// ListOrphanBlobs 查询已无附件引用的 Blob
+	ListOrphanBlobs(limit int) ([]model.Blob, error)
+	// DeleteOrphanBlob 仅当引用计数仍为 0 时删除 Blob 记录，返回是否删除
+	DeleteOrphanBlob(hash string) (bool, error)
+}
+
+type attachmentRepo struct {
+	db *gorm.DB
+}
+
+func (a *attachmentRepo) Create(att *model.Attachment, blob *model.Blob) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		blob.RefCount = 1
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}),
+		}).Create(blob).Error
+		if err != nil {
+			return err
+		}
+		att.BlobHash = blob.Hash
+		return tx.Create(att).Error
+	})
+}
+
+func (a *attachmentRepo) GetByID(id int64) (*model.Attachment, error) {
+	var att model.Attachment
+	if err := a.db.Where("id = ?", id).First(&att).Error; err != nil {
+		return nil, err
+	}
+	return &att, nil
+}
+
+func (a *attachmentRepo) ListByNote(noteID int64) ([]model.Attachment, error) {
+	var list []model.Attachment
+	err := a.db.Where("note_id = ?", noteID).Order("id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (a *attachmentRepo) ListByNotes(noteIDs []int64) ([]model.Attachment, error) {
+	var list []model.Attachment
+	if len(noteIDs) == 0 {
+		return list, nil
+	}
+	err := a.db.Where("note_id IN ?", noteIDs).Order("id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (a *attachmentRepo) Delete(id int64) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		var atts []model.Attachment
+		if err := tx.Where("id = ?", id).Find(&atts).Error; err != nil {
+			return err
+		}
+		if len(atts) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return releaseAttachments(tx, atts)
+	})
+}
+
+func (a *attachmentRepo) ListOrphanBlobs(limit int) ([]model.Blob, error) {
+	var list []model.Blob
+	err := a.db.Where("ref_count <= 0").Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (a *attachmentRepo) DeleteOrphanBlob(hash string) (bool, error) {
+	res := a.db.Where("hash = ? AND ref_count <= 0", hash).Delete(&model.Blob{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// releaseNoteAttachments 删除笔记的全部附件并释放 Blob 引用，需在事务中调用
+func releaseNoteAttachments(tx *gorm.DB, noteIDs []int64) error {
+	var atts []model.Attachment
+	if err := tx.Where("note_id IN ?", noteIDs).Find(&atts).Error; err != nil {
+		return err
+	}
+	return releaseAttachments(tx, atts)
+}
+
+func releaseAttachments(tx *gorm.DB, atts []model.Attachment) error {
+	if len(atts) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(atts))
+	refs := make(map[string]int64)
+	for _, att := range atts {
+		ids = append(ids, att.ID)
+		refs[att.BlobHash]++
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&model.Attachment{}).Error; err != nil {
+		return err
+	}
+	for hash, n := range refs {
+		err := tx.Model(&model.Blob{}).
+			Where("hash = ?", hash).
+			Update("ref_count", gorm.Expr("ref_count - ?", n)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewAttachmentRepo(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepo{db: db}
+}
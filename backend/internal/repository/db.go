@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// 支持的数据库驱动
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
+
+// dialect 封装不同数据库之间有差异的 SQL，仓储层之外不应出现方言相关代码
+type dialect interface {
+	// name 方言名，同时是 migrations 下的子目录名
+	name() string
+	// like 生成带转义的 LIKE 条件，参数需先经过 escapeLike
+	like(column string) string
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) name() string {
+	return DriverMySQL
+}
+
+// MySQL 默认以反斜杠作为 LIKE 转义符
+func (mysqlDialect) like(column string) string {
+	return column + " LIKE ?"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string {
+	return DriverSQLite
+}
+
+// SQLite 没有默认转义符，需要显式声明
+func (sqliteDialect) like(column string) string {
+	return column + ` LIKE ? ESCAPE '\'`
+}
+
+func dialectOf(db *gorm.DB) dialect {
+	if db.Dialector.Name() == DriverSQLite {
+		return sqliteDialect{}
+	}
+	return mysqlDialect{}
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// OpenDB 按驱动打开数据库；sqlite 的 dsn 为数据库文件路径
+func OpenDB(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case DriverMySQL:
+		return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	case DriverSQLite:
+		if dir := filepath.Dir(dsn); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, err
+			}
+		}
+		db, err := gorm.Open(sqlite.Open(dsn+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		// SQLite 同一时刻只允许一个写者，单连接避免 database is locked
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		return db, nil
+	}
+	return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+}
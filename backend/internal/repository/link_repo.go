@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"note-system/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type LinkRepository interface {
+	// ReplaceOutlinks 用新解析的链接整体替换源笔记的出链
+	ReplaceOutlinks(sourceID int64, links []model.NoteLink) error
+	ListOutlinks(sourceID int64) ([]model.NoteLink, error)
+	ListBacklinks(targetID int64) ([]model.NoteLink, error)
+	// ListResolved 查询全部已解析（非悬空）的链接
+	ListResolved() ([]model.NoteLink, error)
+	SetDanglingByTargets(targetIDs []int64, dangling bool) error
+	// ResolveTitle 将指向 title 的悬空标题链接解析到 targetID
+	ResolveTitle(title string, targetID int64) error
+	UpdateTargetTitle(targetID int64, title string) error
+	DeleteBySources(sourceIDs []int64) error
+}
+
+type linkRepo struct {
+	db *gorm.DB
+}
+
+func (l *linkRepo) ReplaceOutlinks(sourceID int64, links []model.NoteLink) error {
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source_id = ?", sourceID).Delete(&model.NoteLink{}).Error; err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+func (l *linkRepo) ListOutlinks(sourceID int64) ([]model.NoteLink, error) {
+	var list []model.NoteLink
+	err := l.db.Where("source_id = ?", sourceID).Order("id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (l *linkRepo) ListBacklinks(targetID int64) ([]model.NoteLink, error) {
+	var list []model.NoteLink
+	err := l.db.Where("target_id = ? AND dangling = ?", targetID, false).Order("source_id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (l *linkRepo) ListResolved() ([]model.NoteLink, error) {
+	var list []model.NoteLink
+	err := l.db.Where("dangling = ? AND target_id > 0", false).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (l *linkRepo) SetDanglingByTargets(targetIDs []int64, dangling bool) error {
+	if len(targetIDs) == 0 {
+		return nil
+	}
+	return l.db.Model(&model.NoteLink{}).
+		Where("target_id IN ?", targetIDs).
+		Update("dangling", dangling).Error
+}
+
+func (l *linkRepo) ResolveTitle(title string, targetID int64) error {
+	return l.db.Model(&model.NoteLink{}).
+		Where("kind = ? AND dangling = ? AND target_title = ?", model.LinkKindTitle, true, title).
+		Updates(map[string]interface{}{
+			"target_id": targetID,
+			"dangling":  false,
+		}).Error
+}
+
+func (l *linkRepo) UpdateTargetTitle(targetID int64, title string) error {
+	return l.db.Model(&model.NoteLink{}).
+		Where("target_id = ?", targetID).
+		Update("target_title", title).Error
+}
+
+func (l *linkRepo) DeleteBySources(sourceIDs []int64) error {
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+	return l.db.Where("source_id IN ?", sourceIDs).Delete(&model.NoteLink{}).Error
+}
+
+func NewLinkRepo(db *gorm.DB) LinkRepository {
+	return &linkRepo{db: db}
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations/<方言>/<版本>_<名称>.up.sql 与对应的 .down.sql，两种方言的版本号必须一一对应
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+const createMigrationTable = "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+	"version BIGINT NOT NULL PRIMARY KEY, " +
+	"name VARCHAR(255) NOT NULL, " +
+	"applied_at DATETIME NOT NULL)"
+
+// Migration 一个版本的结构变更
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState 某个版本在当前数据库中的执行情况
+type MigrationState struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// schemaMigration 已执行版本的记录，映射 schema_migrations 表
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator 按版本号顺序执行内嵌的 SQL 迁移，并在 schema_migrations 中记录
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	list, err := loadMigrations(dialectOf(db).name())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createMigrationTable).Error; err != nil {
+		return nil, fmt.Errorf("创建 schema_migrations 失败: %w", err)
+	}
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// Migrate 执行全部未执行的迁移，服务启动时调用
+func Migrate(db *gorm.DB) ([]Migration, error) {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up()
+}
+
+func loadMigrations(dir string) ([]Migration, error) {
+	root := path.Join("migrations", dir)
+	entries, err := fs.ReadDir(migrationFS, root)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移目录 %s 失败: %w", root, err)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		file := e.Name()
+		var up bool
+		var base string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			up, base = true, strings.TrimSuffix(file, ".up.sql")
+		case strings.HasSuffix(file, ".down.sql"):
+			base = strings.TrimSuffix(file, ".down.sql")
+		default:
+			continue
+		}
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(num, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("迁移文件名不合法: %s", file)
+		}
+		b, err := fs.ReadFile(migrationFS, path.Join(root, file))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("迁移版本 %d 名称不一致: %s / %s", version, m.Name, name)
+		}
+		if up {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// splitStatements 按行尾分号拆分语句，忽略 -- 注释行；迁移文件中的语句不得在行内包含分号结尾
+func splitStatements(sql string) []string {
+	var stmts []string
+	var cur strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(t, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+func (m *Migrator) applied() (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int64]schemaMigration, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+// Status 列出全部迁移及执行时间，未执行的 AppliedAt 为空
+func (m *Migrator) Status() ([]MigrationState, error) {
+	done, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]MigrationState, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := MigrationState{Version: mg.Version, Name: mg.Name}
+		if r, ok := done[mg.Version]; ok {
+			at := r.AppliedAt
+			st.AppliedAt = &at
+		}
+		list = append(list, st)
+	}
+	return list, nil
+}
+
+// Up 按版本号升序执行全部未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up() ([]Migration, error) {
+	done, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, mg := range m.migrations {
+		if _, ok := done[mg.Version]; ok {
+			continue
+		}
+		err := m.run(mg, mg.Up, func(tx *gorm.DB) error {
+			return tx.Create(&schemaMigration{Version: mg.Version, Name: mg.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, err
+		}
+		ran = append(ran, mg)
+	}
+	return ran, nil
+}
+
+// Down 按版本号降序回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	done, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+		mg := m.migrations[i]
+		if _, ok := done[mg.Version]; !ok {
+			continue
+		}
+		err := m.run(mg, mg.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&schemaMigration{Version: mg.Version}).Error
+		})
+		if err != nil {
+			return ran, err
+		}
+		ran = append(ran, mg)
+	}
+	return ran, nil
+}
+
+// run 在事务中执行迁移语句并更新版本记录。MySQL 的 DDL 会隐式提交，
+// 中途失败时已执行的语句不会回滚，需要人工处理后重试
+func (m *Migrator) run(mg Migration, sql string, record func(tx *gorm.DB) error) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(sql) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("迁移 %d_%s 执行失败: %w\n%s", mg.Version, mg.Name, err, stmt)
+			}
+		}
+		return record(tx)
+	})
+}
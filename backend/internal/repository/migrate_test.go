@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"note-system/internal/model"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 基线版本由 AutoMigrate 建表时的模型，只用于构造升级前的数据库
+type baselineNote struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Title     string `gorm:"size:200;not null"`
+	Content   string `gorm:"type:longtext;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	IsDeleted int8 `gorm:"not null;default:0"`
+}
+
+func (baselineNote) TableName() string { return "notes" }
+
+type baselineFragment struct {
+	ID        int64  `gorm:"primaryKey"`
+	NoteID    int64  `index:"idx_note_id"`
+	FragID    string `gorm:"type:varchar(64);uniqueIndex"`
+	Content   string `gorm:"type:longtext"`
+	IsCode    bool
+	VectorID  string `gorm:"type:varchar(128)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineFragment) TableName() string { return "fragments" }
+
+type baselineQARecord struct {
+	ID        int64  `gorm:"primaryKey"`
+	Question  string `gorm:"type:longtext"`
+	Answer    string `gorm:"type:longtext"`
+	Fragments string `gorm:"type:longtext"`
+	CreatedAt time.Time
+}
+
+func (baselineQARecord) TableName() string { return "qa_records" }
+
+// assertSchema 当前全部模型的每一列都必须存在
+func assertSchema(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	models := []interface{}{
+		&model.Note{}, &model.Fragment{}, &model.QARecord{}, &model.NoteTag{}, &model.NoteLink{},
+		&model.Blob{}, &model.Attachment{}, &model.NoteMerge{},
+	}
+	for _, m := range models {
+		s, err := schema.Parse(m, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(s.Table) {
+			t.Errorf("缺少表 %s", s.Table)
+			continue
+		}
+		for _, f := range s.Fields {
+			if f.DBName != "" && !db.Migrator().HasColumn(m, f.DBName) {
+				t.Errorf("表 %s 缺少列 %s", s.Table, f.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	assertSchema(t, openTestDB(t))
+}
+
+// 基线版本的数据库（AutoMigrate 建表、没有 schema_migrations）升级后结构完整，已有数据保留
+func TestMigrateFromBaseline(t *testing.T) {
+	db, err := OpenDB(DriverSQLite, filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&baselineNote{}, &baselineFragment{}, &baselineQARecord{}); err != nil {
+		t.Fatal(err)
+	}
+	old := &baselineNote{Title: "旧笔记", Content: "升级前的内容"}
+	if err := db.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&baselineFragment{NoteID: old.ID, FragID: "f1", Content: "升级前的内容"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := Migrate(db)
+	if err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	if len(ran) == 0 || ran[0].Version != 1 {
+		t.Fatalf("应从版本 1 开始执行，实际 %+v", ran)
+	}
+	assertSchema(t, db)
+
+	// 依赖新增列的查询可以执行，旧数据取默认值
+	repo := NewNoteRepo(db)
+	if err := repo.DeleteByIDs([]int64{old.ID}); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := repo.ListPage(ListQuery{Deleted: true, Limit: 10})
+	if err != nil || len(trash) != 1 || trash[0].DeletedAt == nil {
+		t.Fatalf("回收站查询: %+v, %v", trash, err)
+	}
+	var frag model.Fragment
+	if err := db.Where("frag_id = ?", "f1").First(&frag).Error; err != nil || frag.Source != model.FragSourceNote {
+		t.Fatalf("旧片段: %+v, %v", frag, err)
+	}
+
+	// 全部回滚后只剩迁移记录表
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(len(ran)); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if db.Migrator().HasTable("notes") || db.Migrator().HasTable("attachments") {
+		t.Fatal("回滚后仍有表")
+	}
+}
@@ -0,0 +1,33 @@
+-- 初始表结构，与基线版本 AutoMigrate 生成的 notes、fragments、qa_records 一致；已有数据库上执行时跳过已存在的表
+
+CREATE TABLE IF NOT EXISTS `notes` (
+  `id` bigint AUTO_INCREMENT,
+  `title` varchar(200) NOT NULL,
+  `content` longtext NOT NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `is_deleted` tinyint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `fragments` (
+  `id` bigint AUTO_INCREMENT,
+  `note_id` bigint,
+  `frag_id` varchar(64),
+  `content` longtext,
+  `is_code` boolean,
+  `vector_id` varchar(128),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_fragments_frag_id` (`frag_id`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `qa_records` (
+  `id` bigint AUTO_INCREMENT,
+  `question` longtext,
+  `answer` longtext,
+  `fragments` longtext,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1 @@
+-- 字符集转换不回退：转回旧字符集会丢失中文
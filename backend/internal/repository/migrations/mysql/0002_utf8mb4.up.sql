@@ -0,0 +1,4 @@
+-- 早期数据库按服务器默认字符集建表，中文会变成问号；统一转换为 utf8mb4。之后新建的表建表时即指定 utf8mb4
+ALTER TABLE `notes` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+ALTER TABLE `fragments` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+ALTER TABLE `qa_records` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+DROP INDEX `idx_notes_list_updated` ON `notes`;
+DROP INDEX `idx_notes_list_created` ON `notes`;
+DROP INDEX `idx_notes_list_title` ON `notes`;
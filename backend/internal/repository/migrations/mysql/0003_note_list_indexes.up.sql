@@ -0,0 +1,4 @@
+-- 列表键集分页：(is_deleted, 排序字段, id)
+CREATE INDEX `idx_notes_list_updated` ON `notes` (`is_deleted`, `updated_at`, `id`);
+CREATE INDEX `idx_notes_list_created` ON `notes` (`is_deleted`, `created_at`, `id`);
+CREATE INDEX `idx_notes_list_title` ON `notes` (`is_deleted`, `title`, `id`);
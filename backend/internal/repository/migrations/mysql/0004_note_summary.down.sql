@@ -0,0 +1 @@
+ALTER TABLE `notes` DROP COLUMN `excerpt`, DROP COLUMN `word_count`;
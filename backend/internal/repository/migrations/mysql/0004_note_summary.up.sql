@@ -0,0 +1,2 @@
+-- 列表摘要与字数；excerpt 为 NULL 表示旧数据尚未生成，由服务启动后的后台任务补齐
+ALTER TABLE `notes` ADD COLUMN `excerpt` varchar(512) NULL, ADD COLUMN `word_count` bigint NOT NULL DEFAULT 0;
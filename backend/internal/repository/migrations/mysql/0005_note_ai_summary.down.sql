@@ -0,0 +1 @@
+ALTER TABLE `notes` DROP COLUMN `summary`, DROP COLUMN `suggested_title`, DROP COLUMN `suggested_tags`, DROP COLUMN `summarized_at`;
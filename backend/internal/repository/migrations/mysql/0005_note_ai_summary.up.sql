@@ -0,0 +1,2 @@
+-- LLM 生成的摘要、建议标题与标签；summarized_at 记录摘要对应的笔记版本
+ALTER TABLE `notes` ADD COLUMN `summary` text NULL, ADD COLUMN `suggested_title` varchar(200) NULL, ADD COLUMN `suggested_tags` varchar(1024) NULL, ADD COLUMN `summarized_at` datetime(3) NULL;
@@ -0,0 +1,11 @@
+-- 笔记合并记录：保存合并前目标笔记与被合并笔记的完整内容
+CREATE TABLE IF NOT EXISTS `note_merges` (
+  `id` bigint AUTO_INCREMENT,
+  `target_id` bigint NOT NULL,
+  `target_title` varchar(200),
+  `target_content` longtext,
+  `sources` longtext,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_note_merges_target_id` (`target_id`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS `attachments`;
+DROP TABLE IF EXISTS `blobs`;
+DROP TABLE IF EXISTS `note_links`;
+DROP TABLE IF EXISTS `note_tags`;
+ALTER TABLE `fragments` DROP INDEX `idx_fragments_attachment_id`, DROP COLUMN `page`, DROP COLUMN `attachment_id`, DROP COLUMN `source`;
+ALTER TABLE `notes` DROP INDEX `idx_notes_deleted_at`, DROP COLUMN `deleted_at`, DROP COLUMN `source_url`;
@@ -0,0 +1,56 @@
+-- 基线之后新增的列与表：剪藏来源、回收站时间、附件片段来源，以及标签、链接与附件
+
+ALTER TABLE `notes`
+  ADD COLUMN `source_url` varchar(1024) NULL,
+  ADD COLUMN `deleted_at` datetime(3) NULL,
+  ADD INDEX `idx_notes_deleted_at` (`deleted_at`);
+
+ALTER TABLE `fragments`
+  ADD COLUMN `source` varchar(16) NOT NULL DEFAULT 'note',
+  ADD COLUMN `attachment_id` bigint NOT NULL DEFAULT 0,
+  ADD COLUMN `page` bigint NOT NULL DEFAULT 0,
+  ADD INDEX `idx_fragments_attachment_id` (`attachment_id`);
+
+CREATE TABLE IF NOT EXISTS `note_tags` (
+  `id` bigint AUTO_INCREMENT,
+  `note_id` bigint NOT NULL,
+  `tag` varchar(64) NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uk_note_tag` (`note_id`, `tag`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `note_links` (
+  `id` bigint AUTO_INCREMENT,
+  `source_id` bigint NOT NULL,
+  `target_id` bigint NOT NULL DEFAULT 0,
+  `target_title` varchar(200),
+  `kind` varchar(16) NOT NULL,
+  `dangling` boolean NOT NULL DEFAULT false,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_note_links_source_id` (`source_id`),
+  INDEX `idx_note_links_target_id` (`target_id`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `blobs` (
+  `hash` varchar(64),
+  `size` bigint NOT NULL,
+  `mime` varchar(128),
+  `ref_count` bigint NOT NULL DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`hash`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `attachments` (
+  `id` bigint AUTO_INCREMENT,
+  `note_id` bigint NOT NULL,
+  `blob_hash` varchar(64) NOT NULL,
+  `file_name` varchar(255),
+  `mime` varchar(128),
+  `size` bigint,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_attachments_note_id` (`note_id`),
+  INDEX `idx_attachments_blob_hash` (`blob_hash`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `qa_records`;
+DROP TABLE IF EXISTS `fragments`;
+DROP TABLE IF EXISTS `notes`;
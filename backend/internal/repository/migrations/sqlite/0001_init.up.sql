@@ -0,0 +1,30 @@
+-- 初始表结构，与基线版本 AutoMigrate 生成的 notes、fragments、qa_records 一致；已有数据库上执行时跳过已存在的表
+
+CREATE TABLE IF NOT EXISTS `notes` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `title` text NOT NULL,
+  `content` longtext NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `is_deleted` integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS `fragments` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `note_id` integer,
+  `frag_id` varchar(64),
+  `content` longtext,
+  `is_code` numeric,
+  `vector_id` varchar(128),
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_fragments_frag_id` ON `fragments`(`frag_id`);
+
+CREATE TABLE IF NOT EXISTS `qa_records` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `question` longtext,
+  `answer` longtext,
+  `fragments` longtext,
+  `created_at` datetime
+);
@@ -0,0 +1 @@
+-- SQLite 始终以 UTF-8 存储文本，无需转换；保留此文件使两种数据库的版本号一致
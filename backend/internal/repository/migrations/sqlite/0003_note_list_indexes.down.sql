@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `idx_notes_list_updated`;
+DROP INDEX IF EXISTS `idx_notes_list_created`;
+DROP INDEX IF EXISTS `idx_notes_list_title`;
@@ -0,0 +1,2 @@
+ALTER TABLE `notes` DROP COLUMN `word_count`;
+ALTER TABLE `notes` DROP COLUMN `excerpt`;
@@ -0,0 +1,4 @@
+ALTER TABLE `notes` DROP COLUMN `summarized_at`;
+ALTER TABLE `notes` DROP COLUMN `suggested_tags`;
+ALTER TABLE `notes` DROP COLUMN `suggested_title`;
+ALTER TABLE `notes` DROP COLUMN `summary`;
@@ -0,0 +1,10 @@
+-- 笔记合并记录：保存合并前目标笔记与被合并笔记的完整内容
+CREATE TABLE IF NOT EXISTS `note_merges` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `target_id` integer NOT NULL,
+  `target_title` text,
+  `target_content` longtext,
+  `sources` longtext,
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_note_merges_target_id` ON `note_merges`(`target_id`);
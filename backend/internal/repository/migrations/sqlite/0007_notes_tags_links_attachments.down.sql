@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS `attachments`;
+DROP TABLE IF EXISTS `blobs`;
+DROP TABLE IF EXISTS `note_links`;
+DROP TABLE IF EXISTS `note_tags`;
+DROP INDEX IF EXISTS `idx_fragments_attachment_id`;
+ALTER TABLE `fragments` DROP COLUMN `page`;
+ALTER TABLE `fragments` DROP COLUMN `attachment_id`;
+ALTER TABLE `fragments` DROP COLUMN `source`;
+DROP INDEX IF EXISTS `idx_notes_deleted_at`;
+ALTER TABLE `notes` DROP COLUMN `deleted_at`;
+ALTER TABLE `notes` DROP COLUMN `source_url`;
@@ -0,0 +1,51 @@
+-- 基线之后新增的列与表：剪藏来源、回收站时间、附件片段来源，以及标签、链接与附件
+
+ALTER TABLE `notes` ADD COLUMN `source_url` text NULL;
+ALTER TABLE `notes` ADD COLUMN `deleted_at` datetime NULL;
+CREATE INDEX IF NOT EXISTS `idx_notes_deleted_at` ON `notes`(`deleted_at`);
+
+ALTER TABLE `fragments` ADD COLUMN `source` text NOT NULL DEFAULT 'note';
+ALTER TABLE `fragments` ADD COLUMN `attachment_id` integer NOT NULL DEFAULT 0;
+ALTER TABLE `fragments` ADD COLUMN `page` integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS `idx_fragments_attachment_id` ON `fragments`(`attachment_id`);
+
+CREATE TABLE IF NOT EXISTS `note_tags` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `note_id` integer NOT NULL,
+  `tag` text NOT NULL,
+  `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uk_note_tag` ON `note_tags`(`note_id`, `tag`);
+
+CREATE TABLE IF NOT EXISTS `note_links` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `source_id` integer NOT NULL,
+  `target_id` integer NOT NULL DEFAULT 0,
+  `target_title` text,
+  `kind` text NOT NULL,
+  `dangling` numeric NOT NULL DEFAULT false,
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_note_links_source_id` ON `note_links`(`source_id`);
+CREATE INDEX IF NOT EXISTS `idx_note_links_target_id` ON `note_links`(`target_id`);
+
+CREATE TABLE IF NOT EXISTS `blobs` (
+  `hash` varchar(64),
+  `size` integer NOT NULL,
+  `mime` text,
+  `ref_count` integer NOT NULL DEFAULT 0,
+  `created_at` datetime,
+  PRIMARY KEY (`hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `attachments` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `note_id` integer NOT NULL,
+  `blob_hash` varchar(64) NOT NULL,
+  `file_name` text,
+  `mime` text,
+  `size` integer,
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_attachments_note_id` ON `attachments`(`note_id`);
+CREATE INDEX IF NOT EXISTS `idx_attachments_blob_hash` ON `attachments`(`blob_hash`);
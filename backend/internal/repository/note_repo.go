@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NoteRepository interface {
@@ -12,12 +13,42 @@ type NoteRepository interface {
 	GetByID(id int64) (*model.Note, error)
 	Update(note *model.Note) error
 	Delete(id int64) error
-	List(page, size int) ([]model.Note, int64, error)
-	ListDeleted(page, size int) ([]model.Note, int64, error)
+	// ListPage 按键集游标查询一页笔记
+	ListPage(q ListQuery) ([]model.Note, error)
+	// Count 统计未删除（deleted 为 false）或回收站中的笔记数
+	Count(deleted bool) (int64, error)
+	// TagsByNotes 批量查询笔记的标签
+	TagsByNotes(ids []int64) (map[int64][]string, error)
+	// ListUnsummarized 查询尚未生成摘要的笔记（含回收站）
+	ListUnsummarized(limit int) ([]model.Note, error)
+	// UpdateSummary 只写入笔记的摘要与字数
+	UpdateSummary(note *model.Note) error
+	// UpdateAISummary 只写入 LLM 生成的摘要、建议标题与标签，不改变更新时间
+	UpdateAISummary(note *model.Note) error
 	Restore(id int64) error
 	HardDelete(id int64) error
 	SearchLike(q string, limit int) ([]model.Note, error)
 	UpdateTimes(id int64, createdAt, updatedAt time.Time) error
+	// FindByIDs 按ID批量查询笔记（包含回收站中的笔记）
+	FindByIDs(ids []int64) ([]model.Note, error)
+	DeleteByIDs(ids []int64) error
+	RestoreByIDs(ids []int64) error
+	HardDeleteByIDs(ids []int64) error
+	AddTags(ids []int64, tags []string) error
+	// FindByTitles 按标题批量查询未删除的笔记
+	FindByTitles(titles []string) ([]model.Note, error)
+	// ListTitles 查询全部未删除笔记的ID与标题
+	ListTitles() ([]model.Note, error)
+	// ListExpiredTrashIDs 查询在 before 之前移入回收站的笔记ID
+	ListExpiredTrashIDs(before time.Time, limit int) ([]int64, error)
+	// CreateMerge 写入一条笔记合并记录
+	CreateMerge(m *model.NoteMerge) error
+	// ListMerges 查询合并到该笔记的记录，最近的在前
+	ListMerges(targetID int64) ([]model.NoteMerge, error)
+	// MoveAttachments 把 fromIDs 笔记的附件改挂到 toID 下，返回被移动的附件ID
+	MoveAttachments(fromIDs []int64, toID int64) ([]int64, error)
+	// Transaction 在同一个数据库事务中执行 fn，fn 返回错误时整体回滚
+	Transaction(fn func(repo NoteRepository) error) error
 }
 
 type noteRepo struct {
@@ -28,14 +59,13 @@ type noteRepo struct {
 
 // Create implements NoteRepository.
 func (n *noteRepo) Create(note *model.Note) error {
+	note.Summarize()
 	return n.db.Create(note).Error
 }
 
 // Delete implements NoteRepository.
 func (n *noteRepo) Delete(id int64) error {
-	return n.db.Model(&model.Note{}).
-		Where("id = ?", id).
-		Update("is_deleted", 1).Error
+	return n.DeleteByIDs([]int64{id})
 }
 
 // GetByID implements NoteRepository.
@@ -48,64 +78,153 @@ func (n *noteRepo) GetByID(id int64) (*model.Note, error) {
 	return &note, nil
 }
 
-// List implements NoteRepository.
-func (n *noteRepo) List(page int, size int) ([]model.Note, int64, error) {
-	var (
-		noteList []model.Note
-		total    int64
-	)
-	err := n.db.Model(&model.Note{}).Where("is_deleted = 0").Count(&total).Error
-	if err != nil {
-		return nil, 0, err
+// 列表排序字段
+const (
+	SortUpdatedAt = "updated_at"
+	SortCreatedAt = "created_at"
+	SortTitle     = "title"
+)
+
+// ListQuery 键集分页条件：按 (Sort, id) 排序，从 After 之后取 Limit 条
+type ListQuery struct {
+	Deleted bool
+	Sort    string
+	Desc    bool
+	// After 上一页最后一条的位置，为空表示第一页
+	After *Cursor
+	Limit int
+	// Columns 只查询这些列，为空时查询全部；需包含 id 与排序字段
+	Columns []string
+}
+
+// Cursor 笔记在排序中的位置；Sort 为时间字段时用 Time，为 title 时用 Title
+type Cursor struct {
+	Time  time.Time
+	Title string
+	ID    int64
+}
+
+// CursorOf 取笔记在指定排序字段下的位置
+func CursorOf(note *model.Note, sort string) Cursor {
+	switch sort {
+	case SortCreatedAt:
+		return Cursor{Time: note.CreatedAt, ID: note.ID}
+	case SortTitle:
+		return Cursor{Title: note.Title, ID: note.ID}
 	}
-	err = n.db.Model(&model.Note{}).
-		Where("is_deleted = 0").
-		Order("updated_at DESC").
-		Limit(size).
-		Offset((page - 1) * size).
-		Find(&noteList).Error
+	return Cursor{Time: note.UpdatedAt, ID: note.ID}
+}
+
+// ListPage implements NoteRepository.
+// 以 (排序字段, id) 作为键集，翻页期间有笔记增删改也不会重复或遗漏已越过的位置
+func (n *noteRepo) ListPage(q ListQuery) ([]model.Note, error) {
+	col := SortUpdatedAt
+	switch q.Sort {
+	case SortCreatedAt, SortTitle:
+		col = q.Sort
+	}
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	deleted := 0
+	if q.Deleted {
+		deleted = 1
+	}
+	tx := n.db.Model(&model.Note{}).Where("is_deleted = ?", deleted)
+	if q.After != nil {
+		var v interface{} = q.After.Time
+		if col == SortTitle {
+			v = q.After.Title
+		}
+		tx = tx.Where("("+col+" "+cmp+" ? OR ("+col+" = ? AND id "+cmp+" ?))", v, v, q.After.ID)
+	}
+	if len(q.Columns) > 0 {
+		tx = tx.Select(q.Columns)
+	}
+	var list []model.Note
+	err := tx.Order(col + " " + dir).Order("id " + dir).Limit(q.Limit).Find(&list).Error
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	return noteList, total, nil
+	return list, nil
 }
 
-func (n *noteRepo) ListDeleted(page int, size int) ([]model.Note, int64, error) {
-	var (
-		noteList []model.Note
-		total    int64
-	)
-	err := n.db.Model(&model.Note{}).Where("is_deleted = 1").Count(&total).Error
+// TagsByNotes implements NoteRepository.
+func (n *noteRepo) TagsByNotes(ids []int64) (map[int64][]string, error) {
+	out := make(map[int64][]string, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []model.NoteTag
+	err := n.db.Where("note_id IN ?", ids).Order("id ASC").Find(&rows).Error
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	err = n.db.Model(&model.Note{}).
-		Where("is_deleted = 1").
-		Order("updated_at DESC").
-		Limit(size).
-		Offset((page - 1) * size).
-		Find(&noteList).Error
+	for _, r := range rows {
+		out[r.NoteID] = append(out[r.NoteID], r.Tag)
+	}
+	return out, nil
+}
+
+// ListUnsummarized implements NoteRepository.
+func (n *noteRepo) ListUnsummarized(limit int) ([]model.Note, error) {
+	var list []model.Note
+	err := n.db.Model(&model.Note{}).
+		Select("id", "content").
+		Where("excerpt IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Find(&list).Error
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	return noteList, total, nil
+	return list, nil
 }
 
-func (n *noteRepo) Restore(id int64) error {
+// UpdateSummary implements NoteRepository.
+// 不经过 Updates 的自动时间戳，避免补齐摘要改变笔记的更新时间
+func (n *noteRepo) UpdateSummary(note *model.Note) error {
 	return n.db.Model(&model.Note{}).
-		Where("id = ?", id).
-		Update("is_deleted", 0).Error
+		Where("id = ?", note.ID).
+		UpdateColumns(map[string]interface{}{
+			"excerpt":    note.Excerpt,
+			"word_count": note.WordCount,
+		}).Error
+}
+
+// UpdateAISummary implements NoteRepository.
+func (n *noteRepo) UpdateAISummary(note *model.Note) error {
+	return n.db.Model(note).
+		Select("summary", "suggested_title", "suggested_tags", "summarized_at").
+		UpdateColumns(note).Error
+}
+
+// Count implements NoteRepository.
+func (n *noteRepo) Count(deleted bool) (int64, error) {
+	flag := 0
+	if deleted {
+		flag = 1
+	}
+	var total int64
+	err := n.db.Model(&model.Note{}).Where("is_deleted = ?", flag).Count(&total).Error
+	return total, err
+}
+
+func (n *noteRepo) Restore(id int64) error {
+	return n.RestoreByIDs([]int64{id})
 }
 
 func (n *noteRepo) HardDelete(id int64) error {
-	return n.db.Delete(&model.Note{}, id).Error
+	return n.HardDeleteByIDs([]int64{id})
 }
 
 func (n *noteRepo) SearchLike(q string, limit int) ([]model.Note, error) {
 	var list []model.Note
-	like := "%" + q + "%"
+	d := dialectOf(n.db)
+	like := "%" + escapeLike(q) + "%"
 	err := n.db.Model(&model.Note{}).
-		Where("is_deleted = 0 AND (title LIKE ? OR content LIKE ?)", like, like).
+		Where("is_deleted = 0 AND ("+d.like("title")+" OR "+d.like("content")+")", like, like).
 		Order("updated_at DESC").
 		Limit(limit).
 		Find(&list).Error
@@ -117,11 +236,14 @@ func (n *noteRepo) SearchLike(q string, limit int) ([]model.Note, error) {
 
 // Update implements NoteRepository.
 func (n *noteRepo) Update(note *model.Note) error {
+	note.Summarize()
 	return n.db.Model(note).
 		Where("id = ? AND is_deleted = 0", note.ID).
 		Updates(map[string]interface{}{
-			"title":   note.Title,
-			"content": note.Content,
+			"title":      note.Title,
+			"content":    note.Content,
+			"excerpt":    note.Excerpt,
+			"word_count": note.WordCount,
 		}).Error
 }
 
@@ -134,6 +256,138 @@ func (n *noteRepo) UpdateTimes(id int64, createdAt, updatedAt time.Time) error {
 		}).Error
 }
 
+func (n *noteRepo) FindByIDs(ids []int64) ([]model.Note, error) {
+	var list []model.Note
+	if len(ids) == 0 {
+		return list, nil
+	}
+	err := n.db.Model(&model.Note{}).Where("id IN ?", ids).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (n *noteRepo) FindByTitles(titles []string) ([]model.Note, error) {
+	var list []model.Note
+	if len(titles) == 0 {
+		return list, nil
+	}
+	err := n.db.Model(&model.Note{}).
+		Where("is_deleted = 0 AND title IN ?", titles).
+		Order("id ASC").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (n *noteRepo) ListTitles() ([]model.Note, error) {
+	var list []model.Note
+	err := n.db.Model(&model.Note{}).
+		Select("id", "title").
+		Where("is_deleted = 0").
+		Order("id ASC").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (n *noteRepo) DeleteByIDs(ids []int64) error {
+	return n.db.Model(&model.Note{}).
+		Where("id IN ? AND is_deleted = 0", ids).
+		Updates(map[string]interface{}{
+			"is_deleted": 1,
+			"deleted_at": time.Now(),
+		}).Error
+}
+
+func (n *noteRepo) RestoreByIDs(ids []int64) error {
+	return n.db.Model(&model.Note{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"is_deleted": 0,
+			"deleted_at": nil,
+		}).Error
+}
+
+// ListExpiredTrashIDs 早于保留期的回收站笔记；旧数据没有 deleted_at 时以 updated_at 代替
+func (n *noteRepo) ListExpiredTrashIDs(before time.Time, limit int) ([]int64, error) {
+	var ids []int64
+	err := n.db.Model(&model.Note{}).
+		Where("is_deleted = 1 AND (deleted_at < ? OR (deleted_at IS NULL AND updated_at < ?))", before, before).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// HardDeleteByIDs 物理删除笔记及其标签，并释放附件对 Blob 的引用
+func (n *noteRepo) HardDeleteByIDs(ids []int64) error {
+	return n.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("note_id IN ?", ids).Delete(&model.NoteTag{}).Error; err != nil {
+			return err
+		}
+		if err := releaseNoteAttachments(tx, ids); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.Note{}).Error
+	})
+}
+
+// AddTags 为笔记追加标签，已存在的标签忽略
+func (n *noteRepo) AddTags(ids []int64, tags []string) error {
+	rows := make([]model.NoteTag, 0, len(ids)*len(tags))
+	for _, id := range ids {
+		for _, t := range tags {
+			rows = append(rows, model.NoteTag{NoteID: id, Tag: t})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return n.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+func (n *noteRepo) CreateMerge(m *model.NoteMerge) error {
+	return n.db.Create(m).Error
+}
+
+func (n *noteRepo) ListMerges(targetID int64) ([]model.NoteMerge, error) {
+	var list []model.NoteMerge
+	err := n.db.Where("target_id = ?", targetID).Order("id DESC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (n *noteRepo) MoveAttachments(fromIDs []int64, toID int64) ([]int64, error) {
+	var ids []int64
+	if len(fromIDs) == 0 {
+		return ids, nil
+	}
+	if err := n.db.Model(&model.Attachment{}).Where("note_id IN ?", fromIDs).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	return ids, n.db.Model(&model.Attachment{}).Where("id IN ?", ids).Update("note_id", toID).Error
+}
+
+func (n *noteRepo) Transaction(fn func(repo NoteRepository) error) error {
+	return n.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&noteRepo{db: tx})
+	})
+}
+
 func NewNoteRepo(db *gorm.DB) NoteRepository {
 	return &noteRepo{db: db}
 }
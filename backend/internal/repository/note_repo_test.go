@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"note-system/internal/model"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// openTestDB 在临时目录中打开 SQLite 数据库并执行全部迁移
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := OpenDB(DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if _, err := Migrate(db); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// seedNotes 按 titles 创建笔记，更新时间两两相同，用于检验排序字段相等时按 id 翻页
+func seedNotes(t *testing.T, repo NoteRepository, titles []string) []int64 {
+	t.Helper()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := make([]int64, 0, len(titles))
+	for i, title := range titles {
+		note := &model.Note{Title: title, Content: "正文 " + title}
+		if err := repo.Create(note); err != nil {
+			t.Fatalf("创建笔记失败: %v", err)
+		}
+		at := base.Add(time.Duration(i/2) * time.Hour)
+		if err := repo.UpdateTimes(note.ID, at, at); err != nil {
+			t.Fatalf("修改时间失败: %v", err)
+		}
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+// listAll 从第一页开始按游标翻页到末尾，返回依次读到的笔记ID
+func listAll(t *testing.T, repo NoteRepository, q ListQuery, each func(page []model.Note)) []int64 {
+	t.Helper()
+	var got []int64
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("翻页没有结束")
+		}
+		page, err := repo.ListPage(q)
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		if len(page) == 0 {
+			return got
+		}
+		for _, n := range page {
+			got = append(got, n.ID)
+		}
+		if each != nil {
+			each(page)
+		}
+		cur := CursorOf(&page[len(page)-1], q.Sort)
+		q.After = &cur
+	}
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListPageKeysetCursor(t *testing.T) {
+	repo := NewNoteRepo(openTestDB(t))
+	ids := seedNotes(t, repo, []string{"c", "a", "b", "a", "e", "d", "b"})
+
+	cases := []struct {
+		name string
+		q    ListQuery
+		want []int64
+	}{
+		{"更新时间升序", ListQuery{Sort: SortUpdatedAt, Limit: 2}, ids},
+		{"更新时间降序", ListQuery{Sort: SortUpdatedAt, Desc: true, Limit: 3},
+			[]int64{ids[6], ids[5], ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"标题升序", ListQuery{Sort: SortTitle, Limit: 2},
+			[]int64{ids[1], ids[3], ids[2], ids[6], ids[0], ids[5], ids[4]}},
+		{"只查部分列", ListQuery{Sort: SortCreatedAt, Limit: 4, Columns: []string{"id", "created_at"}}, ids},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := listAll(t, repo, c.q, nil); !equalIDs(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestListPageDeleteWhilePaging(t *testing.T) {
+	repo := NewNoteRepo(openTestDB(t))
+	ids := seedNotes(t, repo, []string{"a", "b", "c", "d", "e"})
+
+	// 每读一页就物理删除该页，游标仍指向已删除的最后一条，后续页不应遗漏
+	got := listAll(t, repo, ListQuery{Sort: SortUpdatedAt, Limit: 2}, func(page []model.Note) {
+		del := make([]int64, 0, len(page))
+		for _, n := range page {
+			del = append(del, n.ID)
+		}
+		if err := repo.HardDeleteByIDs(del); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+	})
+	if !equalIDs(got, ids) {
+		t.Fatalf("got %v, want %v", got, ids)
+	}
+	if n, err := repo.Count(false); err != nil || n != 0 {
+		t.Fatalf("剩余 %d 条笔记, err=%v", n, err)
+	}
+}
+
+func TestListPageDeletedFlag(t *testing.T) {
+	repo := NewNoteRepo(openTestDB(t))
+	ids := seedNotes(t, repo, []string{"a", "b", "c"})
+	if err := repo.DeleteByIDs([]int64{ids[1]}); err != nil {
+		t.Fatal(err)
+	}
+	if got := listAll(t, repo, ListQuery{Limit: 10}, nil); !equalIDs(got, []int64{ids[0], ids[2]}) {
+		t.Fatalf("正常笔记 got %v", got)
+	}
+	if got := listAll(t, repo, ListQuery{Deleted: true, Limit: 10}, nil); !equalIDs(got, []int64{ids[1]}) {
+		t.Fatalf("回收站 got %v", got)
+	}
+}
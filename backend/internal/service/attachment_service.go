@@ -0,0 +1,276 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"note-system/internal/common"
+	"note-system/internal/extract"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"note-system/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 默认单个附件大小上限
+const defaultMaxUploadSize = 20 << 20
+
+// AttachmentService 管理笔记附件：内容按 sha256 去重存入 BlobStore，并通过引用计数回收
+type AttachmentService struct {
+	notes   repository.NoteRepository
+	repo    repository.AttachmentRepository
+	store   storage.BlobStore
+	maxSize int64
+	// locks 按 Blob 哈希串行化上传与回收，避免回收删掉刚写入、尚未登记引用的文件
+	locks hashLocks
+}
+
+// NewAttachmentService maxSize<=0 时使用默认上限 20MB
+func NewAttachmentService(notes repository.NoteRepository, repo repository.AttachmentRepository, store storage.BlobStore, maxSize int64) *AttachmentService {
+	if maxSize <= 0 {
+		maxSize = defaultMaxUploadSize
+	}
+	return &AttachmentService{notes: notes, repo: repo, store: store, maxSize: maxSize}
+}
+
+// MaxSize 单个附件的大小上限（字节）
+func (s *AttachmentService) MaxSize() int64 { return s.maxSize }
+
+// Upload 保存上传的附件，内容相同的文件只存一份
+func (s *AttachmentService) Upload(noteID int64, fileName string, r io.Reader) (*model.Attachment, error) {
+	if noteID <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	if _, err := s.notes.GetByID(noteID); err != nil {
+		return nil, dbError("查询笔记失败", err, common.CodeNoteNotFound)
+	}
+
+	// 先落到临时文件，同时计算哈希与大小
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, Internal("保存附件失败", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return nil, Internal("读取附件失败", err)
+	}
+	if size > s.maxSize {
+		return nil, TooLarge(common.CodeUploadTooLarge, s.maxSize>>20)
+	}
+	if size == 0 {
+		return nil, Validation(common.CodeAttachmentEmpty)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	head := make([]byte, 512)
+	n, _ := tmp.ReadAt(head, 0)
+	mimeType := sniffMIME(head[:n], fileName)
+	if !allowedMIME(mimeType) {
+		return nil, Unsupported(common.CodeUnsupportedType, mimeType)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, Internal("保存附件失败", err)
+	}
+	unlock := s.locks.lock(hash)
+	defer unlock()
+	if err := s.store.Put(hash, tmp, size); err != nil {
+		return nil, Internal("保存附件失败", err)
+	}
+	att := &model.Attachment{NoteID: noteID, FileName: cleanFileName(fileName), MIME: mimeType, Size: size}
+	blob := &model.Blob{Hash: hash, Size: size, MIME: mimeType}
+	if err := s.repo.Create(att, blob); err != nil {
+		return nil, Internal("保存附件失败", err)
+	}
+	return att, nil
+}
+
+func (s *AttachmentService) List(noteID int64) ([]model.Attachment, error) {
+	if noteID <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	list, err := s.repo.ListByNote(noteID)
+	if err != nil {
+		return nil, Internal("查询附件失败", err)
+	}
+	return list, nil
+}
+
+// Open 打开附件内容，调用方负责关闭
+func (s *AttachmentService) Open(id int64) (*model.Attachment, io.ReadCloser, error) {
+	att, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := s.store.Open(att.BlobHash)
+	if err != nil {
+		return nil, nil, Internal("读取附件失败", err)
+	}
+	return att, rc, nil
+}
+
+func (s *AttachmentService) Get(id int64) (*model.Attachment, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	att, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, dbError("查询附件失败", err, common.CodeAttachmentNotFound)
+	}
+	return att, nil
+}
+
+// ExtractText 抽取附件中的文本，不支持的类型返回 extract.ErrUnsupported
+func (s *AttachmentService) ExtractText(att *model.Attachment) ([]extract.Page, error) {
+	if !extract.Supported(att.MIME) {
+		return nil, extract.ErrUnsupported
+	}
+	rc, err := s.store.Open(att.BlobHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return extract.Extract(att.MIME, rc)
+}
+
+// ListByNotes 查询多条笔记的全部附件
+func (s *AttachmentService) ListByNotes(noteIDs []int64) ([]model.Attachment, error) {
+	list, err := s.repo.ListByNotes(noteIDs)
+	if err != nil {
+		return nil, Internal("查询附件失败", err)
+	}
+	return list, nil
+}
+
+func (s *AttachmentService) Delete(id int64) error {
+	if id <= 0 {
+		return Validation(common.CodeInvalidID)
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return dbError("删除附件失败", err, common.CodeAttachmentNotFound)
+	}
+	_, err := s.CollectGarbage()
+	return err
+}
+
+// CollectGarbage 删除已无附件引用的 Blob，返回删除数量
+func (s *AttachmentService) CollectGarbage() (int, error) {
+	removed := 0
+	for {
+		blobs, err := s.repo.ListOrphanBlobs(100)
+		if err != nil {
+			return removed, Internal("查询待回收附件失败", err)
+		}
+		if len(blobs) == 0 {
+			return removed, nil
+		}
+		for _, b := range blobs {
+			ok, err := s.collectBlob(b.Hash)
+			if err != nil {
+				return removed, Internal("回收附件失败", err)
+			}
+			if ok {
+				removed++
+			}
+		}
+		if len(blobs) < 100 {
+			return removed, nil
+		}
+	}
+}
+
+// collectBlob 在该哈希的锁内删除记录与文件，期间同内容的上传会等待，之后重新写入文件
+func (s *AttachmentService) collectBlob(hash string) (bool, error) {
+	unlock := s.locks.lock(hash)
+	defer unlock()
+	// 先删记录，避免删除文件期间被重新引用
+	ok, err := s.repo.DeleteOrphanBlob(hash)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, s.store.Delete(hash)
+}
+
+// hashLocks 按键加锁，不再使用的锁随即释放
+type hashLocks struct {
+	mu    sync.Mutex
+	locks map[string]*hashLock
+}
+
+type hashLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// lock 获取 key 对应的锁，返回解锁函数
+func (l *hashLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*hashLock)
+	}
+	hl := l.locks[key]
+	if hl == nil {
+		hl = &hashLock{}
+		l.locks[key] = hl
+	}
+	hl.waiters++
+	l.mu.Unlock()
+
+	hl.Lock()
+	return func() {
+		hl.Unlock()
+		l.mu.Lock()
+		if hl.waiters--; hl.waiters == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// sniffMIME 以内容嗅探为准，仅在嗅探结果为通用文本/二进制时参考扩展名
+func sniffMIME(head []byte, fileName string) string {
+	sniffed := http.DetectContentType(head)
+	base, _, _ := mime.ParseMediaType(sniffed)
+	if base != "text/plain" && base != "application/octet-stream" {
+		return base
+	}
+	byExt, _, _ := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))))
+	switch {
+	case byExt == "text/markdown", byExt == "text/csv", byExt == "application/json":
+		if base == "text/plain" {
+			return byExt
+		}
+	case strings.HasSuffix(strings.ToLower(fileName), ".md") && base == "text/plain":
+		return "text/markdown"
+	}
+	return base
+}
+
+func allowedMIME(m string) bool {
+	switch {
+	case strings.HasPrefix(m, "image/"), strings.HasPrefix(m, "text/"):
+		return true
+	case m == "application/pdf", m == "application/json":
+		return true
+	}
+	return false
+}
+
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "file"
+	}
+	if len([]rune(name)) > 200 {
+		name = string([]rune(name)[:200])
+	}
+	return name
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"note-system/internal/storage"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type attachmentFixture struct {
+	db    *gorm.DB
+	notes NoteService
+	atts  *AttachmentService
+	store *storage.LocalStore
+}
+
+func newAttachmentFixture(t *testing.T) *attachmentFixture {
+	t.Helper()
+	db := openTestDB(t)
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	noteRepo := repository.NewNoteRepo(db)
+	return &attachmentFixture{
+		db:    db,
+		notes: NewNoteService(noteRepo, 0),
+		atts:  NewAttachmentService(noteRepo, repository.NewAttachmentRepo(db), store, 0),
+		store: store,
+	}
+}
+
+func (f *attachmentFixture) upload(t *testing.T, noteID int64, content string) *model.Attachment {
+	t.Helper()
+	att, err := f.atts.Upload(noteID, "a.txt", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	return att
+}
+
+// refCount 返回 Blob 的引用计数，记录不存在时返回 -1
+func (f *attachmentFixture) refCount(t *testing.T, hash string) int64 {
+	t.Helper()
+	var blobs []model.Blob
+	if err := f.db.Where("hash = ?", hash).Find(&blobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) == 0 {
+		return -1
+	}
+	return blobs[0].RefCount
+}
+
+func (f *attachmentFixture) stored(hash string) bool {
+	rc, err := f.store.Open(hash)
+	if err != nil {
+		return false
+	}
+	rc.Close()
+	return true
+}
+
+func TestAttachmentRefCountAndGC(t *testing.T) {
+	f := newAttachmentFixture(t)
+	ids := createNotes(t, f.notes, "a", "b")
+
+	// 内容相同的附件共用一个 Blob
+	first := f.upload(t, ids[0], "相同的内容")
+	second := f.upload(t, ids[1], "相同的内容")
+	other := f.upload(t, ids[1], "另一份内容")
+	if first.BlobHash != second.BlobHash || first.BlobHash == other.BlobHash {
+		t.Fatalf("哈希不符: %s %s %s", first.BlobHash, second.BlobHash, other.BlobHash)
+	}
+	if n := f.refCount(t, first.BlobHash); n != 2 {
+		t.Fatalf("引用计数 %d, want 2", n)
+	}
+
+	// 删除其中一个附件，另一个仍可读取
+	if err := f.atts.Delete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.refCount(t, first.BlobHash); n != 1 {
+		t.Fatalf("引用计数 %d, want 1", n)
+	}
+	_, rc, err := f.atts.Open(second.ID)
+	if err != nil {
+		t.Fatalf("读取附件失败: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "相同的内容" {
+		t.Fatalf("附件内容 %q", data)
+	}
+
+	// 物理删除笔记释放其全部附件，回收后记录与文件都被删除
+	if _, err := f.notes.BatchNotes(BatchHardDelete, []int64{ids[1]}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.refCount(t, second.BlobHash); n != 0 {
+		t.Fatalf("引用计数 %d, want 0", n)
+	}
+	removed, err := f.atts.CollectGarbage()
+	if err != nil || removed != 2 {
+		t.Fatalf("回收 %d 个, err=%v, want 2", removed, err)
+	}
+	for _, hash := range []string{second.BlobHash, other.BlobHash} {
+		if f.refCount(t, hash) != -1 || f.stored(hash) {
+			t.Fatalf("Blob %s 未被回收", hash)
+		}
+	}
+	if removed, err := f.atts.CollectGarbage(); err != nil || removed != 0 {
+		t.Fatalf("重复回收 %d 个, err=%v", removed, err)
+	}
+
+	// 回收后重新上传相同内容，重新写入文件
+	again := f.upload(t, ids[0], "相同的内容")
+	if n := f.refCount(t, again.BlobHash); n != 1 || !f.stored(again.BlobHash) {
+		t.Fatalf("重新上传后引用计数 %d", n)
+	}
+}
+
+func TestAttachmentDeleteNotFound(t *testing.T) {
+	f := newAttachmentFixture(t)
+	var e *Error
+	if err := f.atts.Delete(12345); !errors.As(err, &e) || e.Kind != KindNotFound {
+		t.Fatalf("err=%v, want not found", err)
+	}
+}
+
+// gcAfterPut 写入文件后立即并发触发一次回收，并等待其完成或超时，
+// 模拟回收恰好发生在写入文件与登记引用之间
+type gcAfterPut struct {
+	storage.BlobStore
+	gc func()
+	wg sync.WaitGroup
+}
+
+func (s *gcAfterPut) Put(key string, r io.Reader, size int64) error {
+	if err := s.BlobStore.Put(key, r, size); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.gc()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(50 * time.Millisecond):
+	}
+	return nil
+}
+
+// 回收发生在上传写入文件之后、登记引用之前时，不能删掉刚写入的文件
+func TestAttachmentUploadDuringGC(t *testing.T) {
+	f := newAttachmentFixture(t)
+	id := createNotes(t, f.notes, "a")[0]
+
+	// 先留下一个引用计数为 0、尚未回收的 Blob
+	old := f.upload(t, id, "并发上传的内容")
+	if err := f.db.Where("id = ?", old.ID).Delete(&model.Attachment{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Model(&model.Blob{}).Where("hash = ?", old.BlobHash).Update("ref_count", 0).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	store := &gcAfterPut{BlobStore: f.store, gc: func() {
+		if _, err := f.atts.CollectGarbage(); err != nil {
+			t.Errorf("回收失败: %v", err)
+		}
+	}}
+	f.atts.store = store
+	att := f.upload(t, id, "并发上传的内容")
+	store.wg.Wait()
+	if n := f.refCount(t, att.BlobHash); n != 1 {
+		t.Fatalf("引用计数 %d, want 1", n)
+	}
+	if !f.stored(att.BlobHash) {
+		t.Fatal("刚上传的附件文件已被回收")
+	}
+}
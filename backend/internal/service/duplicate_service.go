@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"note-system/config"
+	"note-system/internal/common"
+	"note-system/internal/dedup"
+	"note-system/internal/logging"
+	"note-system/internal/model"
+	"note-system/internal/rag"
+	"note-system/internal/repository"
+	"note-system/internal/textutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 重复判定方式，强弱依次递减
+const (
+	DupExact   = "exact"   // 正文纯文本指纹相同
+	DupMinHash = "minhash" // 词元集合高度重合
+	DupVector  = "vector"  // 片段向量质心高度相似
+)
+
+const (
+	// dedupPageSize 扫描时每次从数据库读取的笔记数
+	dedupPageSize = 200
+	// dedupPollInterval 后台扫描重新读取扫描间隔的周期
+	dedupPollInterval = time.Minute
+	// dedupVectorMaxNotes 向量比较需两两计算质心相似度，笔记数超过该值时跳过
+	dedupVectorMaxNotes = 3000
+	// maxMergeSources 单次合并的笔记数上限
+	maxMergeSources = 20
+)
+
+var dupRank = map[string]int{DupExact: 3, DupMinHash: 2, DupVector: 1}
+
+// DuplicatePair 一对重复笔记，A < B；同一对命中多种方式时只保留最强的一种
+type DuplicatePair struct {
+	A      int64   `json:"a"`
+	B      int64   `json:"b"`
+	Method string  `json:"method"`
+	Score  float64 `json:"score"`
+}
+
+type DuplicateNote struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Excerpt   string    `json:"excerpt"`
+	WordCount int       `json:"word_count"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DuplicateCluster 互相重复（直接或经由其他笔记）的一组笔记
+type DuplicateCluster struct {
+	Notes []DuplicateNote `json:"notes"`
+	Pairs []DuplicatePair `json:"pairs"`
+	// Keep 建议保留的笔记：字数最多，相同时取ID最小的
+	Keep int64 `json:"keep"`
+}
+
+// DuplicateReport 一次扫描的结果
+type DuplicateReport struct {
+	ScannedAt time.Time `json:"scanned_at"`
+	// Notes 参与扫描的笔记数
+	Notes int `json:"notes"`
+	// VectorChecked 是否做了向量比较，未配置向量库、笔记过多或向量库不可用时为 false
+	VectorChecked bool               `json:"vector_checked"`
+	Clusters      []DuplicateCluster `json:"clusters"`
+
+	pairs []DuplicatePair
+}
+
+// MergeResult 合并结果；Sources 为合并前的快照，Attachments 为从被合并笔记移到目标笔记的附件
+type MergeResult struct {
+	Target      *model.Note
+	Sources     []model.Note
+	Attachments []int64
+	Merge       *model.NoteMerge
+}
+
+// DuplicateService 检测重复与近似重复的笔记并支持合并。检测依次使用正文指纹、MinHash 与片段向量质心，
+// 结果按连通分量聚类后缓存，可通过 Report 按需刷新，也可配置 dedup.scan_interval_minutes 后台定期扫描
+type DuplicateService struct {
+	repo repository.NoteRepository
+	rag  *RAGService
+	cfg  *config.Holder
+
+	// scanMu 保证同一时间只有一次扫描
+	scanMu sync.Mutex
+	mu     sync.Mutex
+	report *DuplicateReport
+}
+
+func NewDuplicateService(repo repository.NoteRepository, rag *RAGService, cfg *config.Holder) *DuplicateService {
+	return &DuplicateService{repo: repo, rag: rag, cfg: cfg}
+}
+
+// Run 按 dedup.scan_interval_minutes 定期扫描，启用后先扫描一次；作为 worker.Group 的任务运行。
+// 每分钟重新读取间隔，热加载修改后无需重启即可生效，间隔为 0 时暂停扫描
+func (s *DuplicateService) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(dedupPollInterval)
+	defer ticker.Stop()
+	var last time.Time
+	for {
+		if minutes := s.cfg.Get().Dedup.ScanIntervalMinutes; minutes > 0 && time.Since(last) >= time.Duration(minutes)*time.Minute {
+			if r, err := s.Scan(ctx); err != nil {
+				slog.ErrorContext(ctx, "重复笔记扫描失败", "err", err)
+			} else {
+				slog.InfoContext(ctx, "重复笔记扫描完成", "notes", r.Notes, "clusters", len(r.Clusters))
+			}
+			last = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Report 返回最近一次扫描的结果，refresh 为 true 或尚未扫描过时先扫描；
+// 此后已删除的笔记从结果中去掉，笔记信息按当前内容返回
+func (s *DuplicateService) Report(ctx context.Context, refresh bool) (*DuplicateReport, error) {
+	s.mu.Lock()
+	r := s.report
+	s.mu.Unlock()
+	if r == nil || refresh {
+		return s.Scan(ctx)
+	}
+	ids := make([]int64, 0)
+	for _, c := range r.Clusters {
+		for _, n := range c.Notes {
+			ids = append(ids, n.ID)
+		}
+	}
+	notes, err := s.repo.FindByIDs(ids)
+	if err != nil {
+		return nil, Internal("查询笔记失败", err)
+	}
+	alive := make(map[int64]model.Note, len(notes))
+	for _, n := range notes {
+		if n.IsDeleted == 0 {
+			alive[n.ID] = n
+		}
+	}
+	out := *r
+	out.Clusters = buildClusters(r.pairs, alive)
+	return &out, nil
+}
+
+// Scan 扫描全部未删除的笔记并替换缓存的结果
+func (s *DuplicateService) Scan(ctx context.Context) (*DuplicateReport, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	cfg := s.cfg.Get()
+	notes, err := s.loadNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make(map[[2]int64]DuplicatePair)
+	add := func(a, b int64, method string, score float64) {
+		if a > b {
+			a, b = b, a
+		}
+		key := [2]int64{a, b}
+		if old, ok := pairs[key]; ok && dupRank[old.Method] >= dupRank[method] {
+			return
+		}
+		pairs[key] = DuplicatePair{A: a, B: b, Method: method, Score: score}
+	}
+
+	// 正文指纹与 MinHash 签名
+	byPrint := make(map[string]int64)
+	sigs := make([]dedup.Signature, 0, len(notes))
+	sigNotes := make([]int64, 0, len(notes))
+	for _, n := range notes {
+		if textutil.Plain(n.Content, true) == "" {
+			continue
+		}
+		fp := dedup.Fingerprint(n.Content)
+		if first, ok := byPrint[fp]; ok {
+			add(first, n.ID, DupExact, 1)
+		} else {
+			byPrint[fp] = n.ID
+		}
+		terms := uniqueTerms(n.Content)
+		if len(terms) >= dedup.MinTerms {
+			sigs = append(sigs, dedup.NewSignature(terms))
+			sigNotes = append(sigNotes, n.ID)
+		}
+	}
+	for _, c := range dedup.Candidates(sigs) {
+		if sim := sigs[c[0]].Similarity(sigs[c[1]]); sim >= cfg.Dedup.MinHashThreshold {
+			add(sigNotes[c[0]], sigNotes[c[1]], DupMinHash, sim)
+		}
+	}
+
+	report := &DuplicateReport{ScannedAt: time.Now(), Notes: len(notes)}
+	if rag.PineconeEnabled(cfg.Rag) && len(notes) <= dedupVectorMaxNotes {
+		err := s.vectorPairs(ctx, cfg, notes, add)
+		logging.WarnIf(ctx, err, "重复笔记向量比较失败，仅使用文本比较")
+		report.VectorChecked = err == nil
+	}
+
+	alive := make(map[int64]model.Note, len(notes))
+	for _, n := range notes {
+		alive[n.ID] = n
+	}
+	report.pairs = make([]DuplicatePair, 0, len(pairs))
+	for _, p := range pairs {
+		report.pairs = append(report.pairs, p)
+	}
+	report.Clusters = buildClusters(report.pairs, alive)
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+	return report, nil
+}
+
+func (s *DuplicateService) loadNotes(ctx context.Context) ([]model.Note, error) {
+	q := repository.ListQuery{
+		Sort:    repository.SortCreatedAt,
+		Limit:   dedupPageSize,
+		Columns: []string{"id", "title", "content", "excerpt", "word_count", "created_at", "updated_at"},
+	}
+	out := make([]model.Note, 0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := s.repo.ListPage(q)
+		if err != nil {
+			return nil, Internal("查询笔记失败", err)
+		}
+		out = append(out, page...)
+		if len(page) < dedupPageSize {
+			return out, nil
+		}
+		cur := repository.CursorOf(&page[len(page)-1], q.Sort)
+		q.After = &cur
+	}
+}
+
+// vectorPairs 取回各笔记片段的向量求质心，两两比较余弦相似度
+func (s *DuplicateService) vectorPairs(ctx context.Context, cfg *config.Config, notes []model.Note, add func(a, b int64, method string, score float64)) error {
+	byNote, err := s.rag.FragmentIDsByNote(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0)
+	for _, n := range notes {
+		ids = append(ids, byNote[n.ID]...)
+	}
+	vecs, err := rag.PineconeFetch(ctx, cfg.Rag, ids)
+	if err != nil {
+		return err
+	}
+	noteIDs := make([]int64, 0, len(notes))
+	centroids := make([][]float32, 0, len(notes))
+	for _, n := range notes {
+		own := make([][]float32, 0, len(byNote[n.ID]))
+		for _, fid := range byNote[n.ID] {
+			if v, ok := vecs[fid]; ok {
+				own = append(own, v)
+			}
+		}
+		if c := rag.Centroid(own); c != nil {
+			noteIDs = append(noteIDs, n.ID)
+			centroids = append(centroids, c)
+		}
+	}
+	for i := range centroids {
+		for j := i + 1; j < len(centroids); j++ {
+			if sim := float64(rag.Cosine(centroids[i], centroids[j])); sim >= cfg.Dedup.VectorThreshold {
+				add(noteIDs[i], noteIDs[j], DupVector, sim)
+			}
+		}
+	}
+	return nil
+}
+
+// buildClusters 按重复关系聚类，只保留两端都在 alive 中的笔记对；大的簇在前
+func buildClusters(pairs []DuplicatePair, alive map[int64]model.Note) []DuplicateCluster {
+	edges := make([][2]int64, 0, len(pairs))
+	kept := make([]DuplicatePair, 0, len(pairs))
+	for _, p := range pairs {
+		_, okA := alive[p.A]
+		_, okB := alive[p.B]
+		if okA && okB {
+			edges = append(edges, [2]int64{p.A, p.B})
+			kept = append(kept, p)
+		}
+	}
+	groups := dedup.Clusters(edges)
+	index := make(map[int64]int)
+	out := make([]DuplicateCluster, 0, len(groups))
+	for gi, g := range groups {
+		c := DuplicateCluster{Notes: make([]DuplicateNote, 0, len(g)), Pairs: make([]DuplicatePair, 0, len(g)-1)}
+		best := -1
+		for _, id := range g {
+			n := alive[id]
+			index[id] = gi
+			c.Notes = append(c.Notes, DuplicateNote{ID: n.ID, Title: n.Title, Excerpt: n.Excerpt, WordCount: n.WordCount, UpdatedAt: n.UpdatedAt})
+			if n.WordCount > best {
+				best, c.Keep = n.WordCount, n.ID
+			}
+		}
+		out = append(out, c)
+	}
+	for _, p := range kept {
+		c := &out[index[p.A]]
+		c.Pairs = append(c.Pairs, p)
+	}
+	for i := range out {
+		ps := out[i].Pairs
+		sort.Slice(ps, func(a, b int) bool {
+			if ps[a].A != ps[b].A {
+				return ps[a].A < ps[b].A
+			}
+			return ps[a].B < ps[b].B
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Notes) > len(out[j].Notes) })
+	return out
+}
+
+// Merge 把 sourceIDs 合并进 targetID：被合并笔记的正文去重后追加到目标笔记末尾，标签与附件转移到目标笔记，
+// 正文中指向被合并笔记的链接改为指向目标笔记，被合并笔记移入回收站；合并前的内容写入合并记录。
+// 其他笔记中的链接、外部索引由调用方在成功后同步
+func (s *DuplicateService) Merge(targetID int64, sourceIDs []int64) (*MergeResult, error) {
+	if targetID <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	ids := make([]int64, 0, len(sourceIDs))
+	seen := make(map[int64]bool, len(sourceIDs))
+	for _, id := range sourceIDs {
+		if id <= 0 || id == targetID {
+			return nil, Validation(common.CodeMergeSources)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, Validation(common.CodeMergeSources)
+	}
+	if len(ids) > maxMergeSources {
+		return nil, Validation(common.CodeMergeTooMany, maxMergeSources)
+	}
+	res := &MergeResult{}
+	err := s.repo.Transaction(func(repo repository.NoteRepository) error {
+		target, err := repo.GetByID(targetID)
+		if err != nil {
+			return dbError("查询笔记失败", err, common.CodeNoteNotFound)
+		}
+		found, err := repo.FindByIDs(ids)
+		if err != nil {
+			return Internal("查询笔记失败", err)
+		}
+		byID := make(map[int64]model.Note, len(found))
+		for _, n := range found {
+			byID[n.ID] = n
+		}
+		sources := make([]model.Note, 0, len(ids))
+		for _, id := range ids {
+			n, ok := byID[id]
+			if !ok || n.IsDeleted != 0 {
+				return NotFound(common.CodeNoteNotFound)
+			}
+			sources = append(sources, n)
+		}
+		tags, err := repo.TagsByNotes(ids)
+		if err != nil {
+			return Internal("查询标签失败", err)
+		}
+		record := &model.NoteMerge{TargetID: target.ID, TargetTitle: target.Title, TargetContent: target.Content, Sources: make([]model.MergedNote, 0, len(sources))}
+		allTags := make([]string, 0)
+		for _, n := range sources {
+			record.Sources = append(record.Sources, model.MergedNote{ID: n.ID, Title: n.Title, Content: n.Content, Tags: tags[n.ID]})
+			allTags = append(allTags, tags[n.ID]...)
+		}
+		target.Content = redirectLinks(mergeContent(target.Content, sources), sources, target)
+		if err := repo.Update(target); err != nil {
+			return Internal("更新笔记失败", err)
+		}
+		if len(allTags) > 0 {
+			if err := repo.AddTags([]int64{target.ID}, allTags); err != nil {
+				return Internal("合并标签失败", err)
+			}
+		}
+		if res.Attachments, err = repo.MoveAttachments(ids, target.ID); err != nil {
+			return Internal("转移附件失败", err)
+		}
+		if err := repo.DeleteByIDs(ids); err != nil {
+			return Internal("删除被合并笔记失败", err)
+		}
+		if err := repo.CreateMerge(record); err != nil {
+			return Internal("写入合并记录失败", err)
+		}
+		res.Sources, res.Merge = sources, record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Target, err = s.repo.GetByID(targetID); err != nil {
+		return nil, dbError("查询笔记失败", err, common.CodeNoteNotFound)
+	}
+	return res, nil
+}
+
+// Merges 查询合并到该笔记的历史记录
+func (s *DuplicateService) Merges(id int64) ([]model.NoteMerge, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	list, err := s.repo.ListMerges(id)
+	if err != nil {
+		return nil, Internal("查询合并记录失败", err)
+	}
+	return list, nil
+}
+
+// mergeContent 把被合并笔记的正文依次追加到 base 末尾：去掉与笔记标题相同的首行标题，
+// 已在 base 或先前追加的内容中出现过的段落不再重复追加
+func mergeContent(base string, sources []model.Note) string {
+	seen := make(map[string]bool)
+	for _, p := range paragraphs(base) {
+		seen[paragraphKey(p)] = true
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(base, "\n"))
+	for _, src := range sources {
+		parts := make([]string, 0)
+		for i, p := range paragraphs(src.Content) {
+			if i == 0 {
+				p = stripTitle(p, src.Title)
+			}
+			key := paragraphKey(p)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			parts = append(parts, p)
+		}
+		if len(parts) > 0 {
+			fmt.Fprintf(&b, "\n\n---\n\n> 合并自《%s》\n\n%s", src.Title, strings.Join(parts, "\n\n"))
+		}
+	}
+	return b.String()
+}
+
+// stripTitle 段落首行是与笔记标题相同的标题行时去掉该行
+func stripTitle(p, title string) string {
+	first, rest, _ := strings.Cut(p, "\n")
+	if strings.HasPrefix(first, "#") && strings.TrimSpace(strings.TrimLeft(first, "#")) == title {
+		return rest
+	}
+	return p
+}
+
+// paragraphs 按空行切分 Markdown 段落，代码块内的空行不切分
+func paragraphs(md string) []string {
+	out := make([]string, 0)
+	cur := make([]string, 0)
+	fence := false
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, strings.Join(cur, "\n"))
+			cur = cur[:0]
+		}
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fence = !fence
+		}
+		if !fence && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		cur = append(cur, line)
+	}
+	flush()
+	return out
+}
+
+// paragraphKey 段落去掉标记、规整空白并转小写后的文本，用于判断段落是否重复
+func paragraphKey(p string) string {
+	return strings.Join(strings.Fields(strings.ToLower(textutil.Plain(p, true))), " ")
+}
+
+func uniqueTerms(md string) []string {
+	terms := textutil.Terms(textutil.Plain(md, true))
+	seen := make(map[string]struct{}, len(terms))
+	out := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			out = append(out, t)
+		}
+	}
+	return out
+}
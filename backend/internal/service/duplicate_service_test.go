@@ -0,0 +1,47 @@
+package service
+
+import (
+	"note-system/internal/model"
+	"testing"
+)
+
+func TestMergeContent(t *testing.T) {
+	base := "# Go 并发\n\ngoroutine 很轻量。\n\nchannel 用于通信。\n"
+	sources := []model.Note{
+		{ID: 2, Title: "Go 并发", Content: "# Go 并发\n\ngoroutine   很轻量。\n\n**select** 等待多个 channel。\n\n```go\nfunc main() {\n\n\tgo run()\n}\n```"},
+		{ID: 3, Title: "副本", Content: "select 等待多个 channel。\n\nCHANNEL 用于通信。"},
+		{ID: 4, Title: "锁", Content: "## 锁\n\nsync.Mutex 保护共享数据。"},
+	}
+	want := "# Go 并发\n\ngoroutine 很轻量。\n\nchannel 用于通信。" +
+		"\n\n---\n\n> 合并自《Go 并发》\n\n**select** 等待多个 channel。\n\n```go\nfunc main() {\n\n\tgo run()\n}\n```" +
+		"\n\n---\n\n> 合并自《锁》\n\nsync.Mutex 保护共享数据。"
+	if got := mergeContent(base, sources); got != want {
+		t.Fatalf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeContentNothingNew(t *testing.T) {
+	base := "第一段。\n\n第二段。"
+	sources := []model.Note{{ID: 2, Title: "x", Content: "第二段。\r\n\r\n第一段。"}}
+	if got := mergeContent(base, sources); got != base {
+		t.Fatalf("没有新段落时正文不应变化，got %q", got)
+	}
+}
+
+func TestRedirectLinks(t *testing.T) {
+	target := &model.Note{ID: 1, Title: "Go 并发"}
+	sources := []model.Note{{ID: 7, Title: "并发笔记"}, {ID: 17, Title: "旧笔记"}}
+	cases := []struct{ in, want string }{
+		{"见 [[并发笔记]]。", "见 [[Go 并发]]。"},
+		{"见 [[并发笔记|这里]]。", "见 [[Go 并发|这里]]。"},
+		{"[[id:7]] 与 [[id:17|旧的]]", "[[id:1]] 与 [[id:1|旧的]]"},
+		{"[[旧笔记]][[并发笔记]]", "[[Go 并发]][[Go 并发]]"},
+		// 只替换完整的链接：其他笔记的ID、标题前缀与普通文本不变
+		{"[[id:70]] [[id:171]] [[并发笔记二]] 并发笔记", "[[id:70]] [[id:171]] [[并发笔记二]] 并发笔记"},
+	}
+	for _, c := range cases {
+		if got := redirectLinks(c.in, sources, target); got != c.want {
+			t.Errorf("redirectLinks(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
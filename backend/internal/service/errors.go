@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"note-system/internal/common"
+
+	"gorm.io/gorm"
+)
+
+// Kind 错误类别，由接口层映射为 HTTP 状态
+type Kind uint8
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindTooLarge
+	KindUnsupported
+	KindUnprocessable
+	KindUpstream
+	KindUnavailable
+)
+
+// kindCodes 各类别的通用业务码
+var kindCodes = map[Kind]int{
+	KindInternal:      common.CodeInternal,
+	KindValidation:    common.CodeInvalidParam,
+	KindNotFound:      common.CodeNotFound,
+	KindConflict:      common.CodeConflict,
+	KindTooLarge:      common.CodeTooLarge,
+	KindUnsupported:   common.CodeUnsupported,
+	KindUnprocessable: common.CodeUnprocessable,
+	KindUpstream:      common.CodeUpstream,
+	KindUnavailable:   common.CodeUnavailable,
+}
+
+// Error 业务错误：Kind 决定 HTTP 状态，Code 为对外稳定的业务码，Args 用于填充多语言消息；
+// Err 为底层原因，只写入日志，不返回给客户端
+type Error struct {
+	Kind Kind
+	Code int
+	Args []interface{}
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := common.Message(common.LangZH, e.Code, e.Args...)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is 目标为类别哨兵（如 ErrNotFound）时按类别匹配，否则按业务码匹配
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || t.Kind != e.Kind {
+		return false
+	}
+	return t.Code == kindCodes[t.Kind] || t.Code == e.Code
+}
+
+// 类别哨兵，配合 errors.Is 使用
+var (
+	ErrNotFound   = &Error{Kind: KindNotFound, Code: common.CodeNotFound}
+	ErrValidation = &Error{Kind: KindValidation, Code: common.CodeInvalidParam}
+	ErrConflict   = &Error{Kind: KindConflict, Code: common.CodeConflict}
+	ErrUpstream   = &Error{Kind: KindUpstream, Code: common.CodeUpstream}
+	// ErrUploadTooLarge 附件超过大小上限
+	ErrUploadTooLarge = &Error{Kind: KindTooLarge, Code: common.CodeUploadTooLarge}
+)
+
+func NotFound(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Args: args}
+}
+
+func Validation(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindValidation, Code: code, Args: args}
+}
+
+func Conflict(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Code: code, Args: args}
+}
+
+func TooLarge(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindTooLarge, Code: code, Args: args}
+}
+
+func Unsupported(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindUnsupported, Code: code, Args: args}
+}
+
+func Unprocessable(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindUnprocessable, Code: code, Args: args}
+}
+
+// Upstream 外部依赖（ES、向量库、LLM 等）调用失败
+func Upstream(err error) *Error {
+	return &Error{Kind: KindUpstream, Code: common.CodeUpstream, Err: err}
+}
+
+// Unavailable 功能所需的依赖未配置或暂不可用
+func Unavailable(code int, args ...interface{}) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Args: args}
+}
+
+// Internal 数据库、存储等内部错误，op 描述失败的操作，只出现在日志中
+func Internal(op string, err error) *Error {
+	return &Error{Kind: KindInternal, Code: common.CodeInternal, Err: fmt.Errorf("%s: %w", op, err)}
+}
+
+// dbError 记录不存在转为 notFound 业务码，其余视为内部错误
+func dbError(op string, err error, notFound int) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound(notFound)
+	}
+	return Internal(op, err)
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"note-system/internal/common"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	wikiLinkRe  = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+	codeBlockRe = regexp.MustCompile("(?s)```.*?```")
+)
+
+// WikiRef 笔记内容中的一处 wiki 链接
+type WikiRef struct {
+	Kind  string
+	Title string
+	ID    int64
+}
+
+// ParseWikiLinks 解析 [[笔记标题]]、[[笔记标题|别名]] 与 [[id:123]]，忽略代码块并去重
+func ParseWikiLinks(content string) []WikiRef {
+	content = codeBlockRe.ReplaceAllString(content, "")
+	refs := make([]WikiRef, 0)
+	seen := make(map[WikiRef]struct{})
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(content, -1) {
+		target := m[1]
+		if i := strings.Index(target, "|"); i >= 0 {
+			target = target[:i]
+		}
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		ref := WikiRef{Kind: model.LinkKindTitle, Title: target}
+		if strings.HasPrefix(target, "id:") {
+			id, err := strconv.ParseInt(strings.TrimSpace(target[3:]), 10, 64)
+			if err != nil || id <= 0 {
+				continue
+			}
+			ref = WikiRef{Kind: model.LinkKindID, ID: id}
+		}
+		if _, ok := seen[ref]; ok {
+			continue
+		}
+		seen[ref] = struct{}{}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// LinkedNote 出链/反链列表中的一项
+type LinkedNote struct {
+	NoteID   int64  `json:"note_id"`
+	Title    string `json:"title"`
+	Kind     string `json:"kind"`
+	Dangling bool   `json:"dangling"`
+}
+
+type GraphNode struct {
+	ID     int64  `json:"id"`
+	Title  string `json:"title"`
+	Degree int    `json:"degree"`
+}
+
+type GraphEdge struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+}
+
+// Graph 知识图谱：节点为未删除的笔记，边为已解析的链接
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// LinkService 维护笔记之间的 wiki 链接
+type LinkService struct {
+	notes repository.NoteRepository
+	links repository.LinkRepository
+}
+
+func NewLinkService(notes repository.NoteRepository, links repository.LinkRepository) *LinkService {
+	return &LinkService{notes: notes, links: links}
+}
+
+// SyncNote 笔记保存后重新解析其出链，并解析此前指向该笔记标题的悬空链接
+func (s *LinkService) SyncNote(note *model.Note) error {
+	if note == nil {
+		return nil
+	}
+	refs := ParseWikiLinks(note.Content)
+	titles := make([]string, 0, len(refs))
+	ids := make([]int64, 0, len(refs))
+	for _, r := range refs {
+		if r.Kind == model.LinkKindID {
+			ids = append(ids, r.ID)
+		} else {
+			titles = append(titles, r.Title)
+		}
+	}
+	byTitle := make(map[string]int64)
+	titled, err := s.notes.FindByTitles(titles)
+	if err != nil {
+		return err
+	}
+	for _, n := range titled {
+		// 同名笔记取ID最小的一条
+		if _, ok := byTitle[n.Title]; !ok {
+			byTitle[n.Title] = n.ID
+		}
+	}
+	byID := make(map[int64]model.Note)
+	found, err := s.notes.FindByIDs(ids)
+	if err != nil {
+		return err
+	}
+	for _, n := range found {
+		byID[n.ID] = n
+	}
+
+	links := make([]model.NoteLink, 0, len(refs))
+	for _, r := range refs {
+		link := model.NoteLink{SourceID: note.ID, Kind: r.Kind}
+		if r.Kind == model.LinkKindID {
+			link.TargetID = r.ID
+			target, ok := byID[r.ID]
+			link.Dangling = !ok || target.IsDeleted != 0
+			if ok {
+				link.TargetTitle = target.Title
+			}
+		} else {
+			link.TargetTitle = r.Title
+			link.TargetID, link.Dangling = byTitle[r.Title], byTitle[r.Title] == 0
+		}
+		if link.TargetID == note.ID {
+			continue
+		}
+		links = append(links, link)
+	}
+	if err := s.links.ReplaceOutlinks(note.ID, links); err != nil {
+		return err
+	}
+	if err := s.links.SetDanglingByTargets([]int64{note.ID}, false); err != nil {
+		return err
+	}
+	return s.links.ResolveTitle(note.Title, note.ID)
+}
+
+// Rename 笔记改名后，改写其他笔记中指向它的 [[旧标题]]，返回内容被改写的笔记
+func (s *LinkService) Rename(note *model.Note, oldTitle string) ([]*model.Note, error) {
+	if note == nil || oldTitle == note.Title {
+		return nil, nil
+	}
+	backs, err := s.links.ListBacklinks(note.ID)
+	if err != nil {
+		return nil, err
+	}
+	sourceIDs := make([]int64, 0, len(backs))
+	for _, l := range backs {
+		if l.Kind == model.LinkKindTitle {
+			sourceIDs = append(sourceIDs, l.SourceID)
+		}
+	}
+	sources, err := s.notes.FindByIDs(sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]*model.Note, 0, len(sources))
+	for i := range sources {
+		src := &sources[i]
+		if src.IsDeleted != 0 {
+			continue
+		}
+		content := strings.ReplaceAll(src.Content, "[["+oldTitle+"]]", "[["+note.Title+"]]")
+		content = strings.ReplaceAll(content, "[["+oldTitle+"|", "[["+note.Title+"|")
+		if content == src.Content {
+			continue
+		}
+		src.Content = content
+		if err := s.notes.Update(src); err != nil {
+			return changed, err
+		}
+		changed = append(changed, src)
+	}
+	return changed, s.links.UpdateTargetTitle(note.ID, note.Title)
+}
+
+// Redirect 笔记合并后，把其他笔记中指向被合并笔记的链接改写为指向目标笔记并重新解析，返回内容被改写的笔记。
+// 需在被合并笔记的链接标记为悬空之前调用
+func (s *LinkService) Redirect(sources []model.Note, target *model.Note) ([]*model.Note, error) {
+	if target == nil || len(sources) == 0 {
+		return nil, nil
+	}
+	skip := map[int64]bool{target.ID: true}
+	for _, src := range sources {
+		skip[src.ID] = true
+	}
+	refIDs := make([]int64, 0)
+	for _, src := range sources {
+		backs, err := s.links.ListBacklinks(src.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range backs {
+			if !skip[l.SourceID] {
+				skip[l.SourceID] = true
+				refIDs = append(refIDs, l.SourceID)
+			}
+		}
+	}
+	refs, err := s.notes.FindByIDs(refIDs)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]*model.Note, 0, len(refs))
+	for i := range refs {
+		ref := &refs[i]
+		if ref.IsDeleted != 0 {
+			continue
+		}
+		content := redirectLinks(ref.Content, sources, target)
+		if content != ref.Content {
+			ref.Content = content
+			if err := s.notes.Update(ref); err != nil {
+				return changed, err
+			}
+			changed = append(changed, ref)
+		}
+		// 同名笔记合并时内容不变，也要重新解析，让标题链接指向目标笔记
+		if err := s.SyncNote(ref); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// redirectLinks 把内容中指向 sources 的 [[标题]]、[[标题|别名]] 与 [[id:N]] 改写为指向 target
+func redirectLinks(content string, sources []model.Note, target *model.Note) string {
+	pairs := make([]string, 0, len(sources)*8)
+	for _, src := range sources {
+		from, to := strconv.FormatInt(src.ID, 10), strconv.FormatInt(target.ID, 10)
+		pairs = append(pairs,
+			"[["+src.Title+"]]", "[["+target.Title+"]]",
+			"[["+src.Title+"|", "[["+target.Title+"|",
+			"[[id:"+from+"]]", "[[id:"+to+"]]",
+			"[[id:"+from+"|", "[[id:"+to+"|",
+		)
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
+
+// RemoveNotes 笔记被删除后将指向它们的链接标记为悬空；物理删除时同时移除它们的出链
+func (s *LinkService) RemoveNotes(ids []int64, hard bool) error {
+	if hard {
+		if err := s.links.DeleteBySources(ids); err != nil {
+			return err
+		}
+	}
+	return s.links.SetDanglingByTargets(ids, true)
+}
+
+// Outlinks 查询笔记引用的其他笔记
+func (s *LinkService) Outlinks(id int64) ([]LinkedNote, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	links, err := s.links.ListOutlinks(id)
+	if err != nil {
+		return nil, Internal("查询出链失败", err)
+	}
+	out := make([]LinkedNote, 0, len(links))
+	for _, l := range links {
+		out = append(out, LinkedNote{NoteID: l.TargetID, Title: l.TargetTitle, Kind: l.Kind, Dangling: l.Dangling})
+	}
+	return out, nil
+}
+
+// Backlinks 查询引用了该笔记的其他未删除笔记
+func (s *LinkService) Backlinks(id int64) ([]LinkedNote, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	links, err := s.links.ListBacklinks(id)
+	if err != nil {
+		return nil, Internal("查询反链失败", err)
+	}
+	ids := make([]int64, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.SourceID)
+	}
+	sources, err := s.notes.FindByIDs(ids)
+	if err != nil {
+		return nil, Internal("查询反链失败", err)
+	}
+	byID := make(map[int64]model.Note, len(sources))
+	for _, n := range sources {
+		byID[n.ID] = n
+	}
+	out := make([]LinkedNote, 0, len(links))
+	for _, l := range links {
+		src, ok := byID[l.SourceID]
+		if !ok || src.IsDeleted != 0 {
+			continue
+		}
+		out = append(out, LinkedNote{NoteID: src.ID, Title: src.Title, Kind: l.Kind})
+	}
+	return out, nil
+}
+
+// Graph 返回全部笔记及其链接关系
+func (s *LinkService) Graph() (*Graph, error) {
+	notes, err := s.notes.ListTitles()
+	if err != nil {
+		return nil, Internal("查询笔记失败", err)
+	}
+	links, err := s.links.ListResolved()
+	if err != nil {
+		return nil, Internal("查询链接失败", err)
+	}
+	index := make(map[int64]int, len(notes))
+	g := &Graph{Nodes: make([]GraphNode, 0, len(notes)), Edges: make([]GraphEdge, 0, len(links))}
+	for i, n := range notes {
+		index[n.ID] = i
+		g.Nodes = append(g.Nodes, GraphNode{ID: n.ID, Title: n.Title})
+	}
+	seen := make(map[GraphEdge]struct{}, len(links))
+	for _, l := range links {
+		si, ok1 := index[l.SourceID]
+		ti, ok2 := index[l.TargetID]
+		if !ok1 || !ok2 {
+			continue
+		}
+		e := GraphEdge{Source: l.SourceID, Target: l.TargetID}
+		if _, ok := seen[e]; ok {
+			continue
+		}
+		seen[e] = struct{}{}
+		g.Edges = append(g.Edges, e)
+		g.Nodes[si].Degree++
+		g.Nodes[ti].Degree++
+	}
+	return g, nil
+}
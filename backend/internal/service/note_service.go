@@ -1,47 +1,100 @@
 package service
 
 import (
-	"errors"
+	"note-system/internal/common"
 	"note-system/internal/model"
 	"note-system/internal/repository"
+	"strings"
 	"time"
-
-	"gorm.io/gorm"
+	"unicode/utf8"
 )
 
 type NoteService interface {
 	// CreateNote 创建笔记，接收标题和内容，返回创建后的笔记和错误
 	CreateNote(title, content string) (*model.Note, error)
+	// CreateClippedNote 创建剪藏笔记并记录原文地址
+	CreateClippedNote(title, content, sourceURL string) (*model.Note, error)
 	// GetNoteByID 根据ID查询笔记，接收ID，返回笔记和错误
 	GetNoteById(id int64) (*model.Note, error)
 	// UpdateNote 更新笔记，接收ID、新标题、新内容，返回错误
 	UpdateNote(id int64, newTitle, newContent string) error
 	// DeleteNote 删除笔记，接收ID，返回错误
 	DeleteNote(id int64) error
-	// ListNotes 分页查询笔记列表，接收页码和每页大小，返回笔记列表、总条数、错误
-	ListNotes(page, size int) ([]model.Note, int64, error)
-	ListDeleted(page, size int) ([]model.Note, int64, error)
+	// ListNotes 按游标分页查询笔记列表
+	ListNotes(opts ListOptions) (*NotePage, error)
+	// ListDeleted 按游标分页查询回收站
+	ListDeleted(opts ListOptions) (*NotePage, error)
 	Restore(id int64) error
 	HardDelete(id int64) error
 	SearchLike(q string, limit int) ([]model.Note, error)
 	SetNoteTimes(id int64, createdAt, updatedAt time.Time) error
+	// BatchNotes 在同一事务中对多条笔记执行批量操作，返回逐条结果
+	BatchNotes(action string, ids []int64, tags []string) ([]BatchResult, error)
+	// ListByIDs 按ID批量查询未删除的笔记
+	ListByIDs(ids []int64) ([]model.Note, error)
+	// PurgeExpiredTrash 物理删除一批（最多 maxBatchSize 条）超过保留期的回收站笔记，返回被删除的ID；
+	// 返回空表示已清理完毕。调用方每批之后即可同步外部索引，作为可中断的检查点
+	PurgeExpiredTrash(now time.Time) ([]int64, error)
+	// BackfillSummaries 为升级前的笔记补齐摘要与字数，每批之后检查 stop，返回处理条数
+	BackfillSummaries(stop <-chan struct{}) (int, error)
+}
+
+// 批量操作类型
+const (
+	BatchDelete     = "delete"
+	BatchRestore    = "restore"
+	BatchHardDelete = "hard_delete"
+	BatchAddTag     = "add_tag"
+	BatchReindex    = "reindex"
+)
+
+// 单次批量操作的最大笔记数
+const maxBatchSize = 500
+
+// BatchResult 批量操作中单条笔记的处理结果，失败时 Code 为业务码、Error 为对应消息
+type BatchResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Code  int    `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
 }
 
 type noteService struct {
 	repo repository.NoteRepository
+	// trashRetention 回收站保留期，<=0 表示永久保留
+	trashRetention time.Duration
 }
 
 // CreateNote implements NoteService.
 func (n *noteService) CreateNote(title string, content string) (*model.Note, error) {
 	//业务校验：标题不能为空
 	if title == "" {
-		return nil, errors.New("笔记标题不能为空")
+		return nil, Validation(common.CodeTitleRequired)
 	}
 	//构建Note模型（业务层组装数据，Repository只负责存储）
 	note := &model.Note{Title: title, Content: content}
 	//调用Repository层的Create方法，存储数据
 	if err := n.repo.Create(note); err != nil {
-		return nil, errors.New("创建笔记失败" + err.Error())
+		return nil, Internal("创建笔记失败", err)
+	}
+	return note, nil
+}
+
+// CreateClippedNote implements NoteService.
+func (n *noteService) CreateClippedNote(title string, content string, sourceURL string) (*model.Note, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = sourceURL
+	}
+	if utf8.RuneCountInString(title) > 200 {
+		title = string([]rune(title)[:200])
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, Validation(common.CodeContentRequired)
+	}
+	note := &model.Note{Title: title, Content: content, SourceURL: sourceURL}
+	if err := n.repo.Create(note); err != nil {
+		return nil, Internal("创建笔记失败", err)
 	}
 	return note, nil
 }
@@ -50,15 +103,14 @@ func (n *noteService) CreateNote(title string, content string) (*model.Note, err
 func (n *noteService) DeleteNote(id int64) error {
 	// 业务校验：ID 必须大于 0
 	if id <= 0 {
-		return errors.New("笔记ID不合法(必须大于0)")
+		return Validation(common.CodeInvalidID)
 	}
-	_, err := n.repo.GetByID(id)
-	if err != nil {
-		return errors.New("未查询到笔记" + err.Error())
+	if _, err := n.repo.GetByID(id); err != nil {
+		return dbError("查询笔记失败", err, common.CodeNoteNotFound)
 	}
 	// 调用 Repository 层删除（逻辑删除）
 	if err := n.repo.Delete(id); err != nil {
-		return errors.New("删除笔记失败：" + err.Error())
+		return Internal("删除笔记失败", err)
 	}
 
 	return nil
@@ -67,63 +119,158 @@ func (n *noteService) DeleteNote(id int64) error {
 // GetNoteById implements NoteService.
 func (n *noteService) GetNoteById(id int64) (*model.Note, error) {
 	if id <= 0 {
-		return nil, errors.New("笔记ID不合法(必须大于0)")
+		return nil, Validation(common.CodeInvalidID)
 	}
 	// 调用 Repository 层查询
 	note, err := n.repo.GetByID(id)
 	if err != nil {
-		// 区分错误类型：如果是记录不存在，返回明确的业务错误
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("未找到该笔记(可能已删除或ID不存在)")
-		}
-		return nil, errors.New("查询笔记失败：" + err.Error())
+		// 区分错误类型：记录不存在返回 NotFound，其余为内部错误
+		return nil, dbError("查询笔记失败", err, common.CodeNoteNotFound)
 	}
 	return note, nil
 }
 
 // ListNotes implements NoteService.
-func (n *noteService) ListNotes(page int, size int) ([]model.Note, int64, error) {
-	// 业务校验：页码至少为 1，每页大小至少为 1，最多为 100（避免查询过多数据）
-	if page < 1 {
-		page = 1 // 默认页码 1
+func (n *noteService) ListNotes(opts ListOptions) (*NotePage, error) {
+	return n.listPage(opts, false)
+}
+
+// ListDeleted implements NoteService.
+func (n *noteService) ListDeleted(opts ListOptions) (*NotePage, error) {
+	page, err := n.listPage(opts, true)
+	if err != nil {
+		return nil, err
 	}
-	if size < 1 || size > 100 {
-		size = 10 // 默认每页 10 条
+	if n.trashRetention > 0 {
+		list := page.List
+		for i := range list {
+			deletedAt := list[i].UpdatedAt
+			if list[i].DeletedAt != nil {
+				deletedAt = *list[i].DeletedAt
+			}
+			purgeAt := deletedAt.Add(n.trashRetention)
+			list[i].PurgeAt = &purgeAt
+		}
 	}
+	return page, nil
+}
 
-	list, total, err := n.repo.List(page, size)
+func (n *noteService) listPage(opts ListOptions, deleted bool) (*NotePage, error) {
+	q, err := opts.query()
 	if err != nil {
-		return nil, 0, errors.New("查询笔记列表失败:" + err.Error())
+		return nil, err
+	}
+	q.Deleted = deleted
+	fields, cols, err := opts.columns(deleted, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	q.Columns = cols
+	// 多取一条判断是否还有下一页
+	q.Limit++
+	list, err := n.repo.ListPage(q)
+	if err != nil {
+		return nil, Internal("查询笔记列表失败", err)
+	}
+	page := &NotePage{List: list, fields: fields}
+	if len(list) == q.Limit {
+		page.List = list[:len(list)-1]
+		page.HasMore = true
+		last := page.List[len(page.List)-1]
+		page.NextCursor = encodeCursor(q.Sort, q.Desc, repository.CursorOf(&last, q.Sort))
+	}
+	if containsField(fields, "tags") && len(page.List) > 0 {
+		ids := make([]int64, 0, len(page.List))
+		for _, note := range page.List {
+			ids = append(ids, note.ID)
+		}
+		tags, err := n.repo.TagsByNotes(ids)
+		if err != nil {
+			return nil, Internal("查询笔记标签失败", err)
+		}
+		for i := range page.List {
+			page.List[i].Tags = tags[page.List[i].ID]
+		}
+	}
+	if opts.WithTotal {
+		total, err := n.repo.Count(deleted)
+		if err != nil {
+			return nil, Internal("统计笔记数失败", err)
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// BackfillSummaries implements NoteService.
+func (n *noteService) BackfillSummaries(stop <-chan struct{}) (int, error) {
+	done := 0
+	for {
+		select {
+		case <-stop:
+			return done, nil
+		default:
+		}
+		list, err := n.repo.ListUnsummarized(100)
+		if err != nil {
+			return done, Internal("查询待生成摘要的笔记失败", err)
+		}
+		if len(list) == 0 {
+			return done, nil
+		}
+		for i := range list {
+			list[i].Summarize()
+			if err := n.repo.UpdateSummary(&list[i]); err != nil {
+				return done, Internal("写入笔记摘要失败", err)
+			}
+			done++
+		}
 	}
-	return list, total, nil
 }
 
-func (n *noteService) ListDeleted(page int, size int) ([]model.Note, int64, error) {
-	if page < 1 {
-		page = 1
+// PurgeExpiredTrash implements NoteService.
+func (n *noteService) PurgeExpiredTrash(now time.Time) ([]int64, error) {
+	if n.trashRetention <= 0 {
+		return nil, nil
 	}
-	if size < 1 || size > 100 {
-		size = 10
+	ids, err := n.repo.ListExpiredTrashIDs(now.Add(-n.trashRetention), maxBatchSize)
+	if err != nil {
+		return nil, Internal("查询过期回收站笔记失败", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
 	}
-	list, total, err := n.repo.ListDeleted(page, size)
+	results, err := n.BatchNotes(BatchHardDelete, ids, nil)
 	if err != nil {
-		return nil, 0, errors.New("查询回收站失败:" + err.Error())
+		return nil, err
+	}
+	purged := make([]int64, 0, len(results))
+	for _, r := range results {
+		if r.OK {
+			purged = append(purged, r.ID)
+		}
 	}
-	return list, total, nil
+	return purged, nil
 }
 
 func (n *noteService) Restore(id int64) error {
-	if id <= 0 {
-		return errors.New("笔记ID不合法(必须大于0)")
+	if err := n.checkTarget(BatchRestore, id); err != nil {
+		return err
+	}
+	if err := n.repo.Restore(id); err != nil {
+		return Internal("恢复笔记失败", err)
 	}
-	return n.repo.Restore(id)
+	return nil
 }
 
 func (n *noteService) HardDelete(id int64) error {
-	if id <= 0 {
-		return errors.New("笔记ID不合法(必须大于0)")
+	if err := n.checkTarget(BatchHardDelete, id); err != nil {
+		return err
+	}
+	if err := n.repo.HardDelete(id); err != nil {
+		return Internal("彻底删除笔记失败", err)
 	}
-	return n.repo.HardDelete(id)
+	return nil
 }
 
 func (n *noteService) SearchLike(q string, limit int) ([]model.Note, error) {
@@ -139,20 +286,17 @@ func (n *noteService) SearchLike(q string, limit int) ([]model.Note, error) {
 func (n *noteService) UpdateNote(id int64, newTitle string, newContent string) error {
 	// 业务校验 1：ID 必须大于 0
 	if id <= 0 {
-		return errors.New("笔记ID不合法（必须大于0）")
+		return Validation(common.CodeInvalidID)
 	}
 	// 业务校验 2：新标题不能为空
 	if newTitle == "" {
-		return errors.New("笔记标题不能为空")
+		return Validation(common.CodeTitleRequired)
 	}
 
 	// 先查询笔记是否存在（避免更新不存在的笔记）
 	note, err := n.repo.GetByID(id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("未找到该笔记，无法更新")
-		}
-		return errors.New("查询笔记失败：" + err.Error())
+		return dbError("查询笔记失败", err, common.CodeNoteNotFound)
 	}
 
 	// 组装更新数据
@@ -161,19 +305,162 @@ func (n *noteService) UpdateNote(id int64, newTitle string, newContent string) e
 
 	// 调用 Repository 层更新
 	if err := n.repo.Update(note); err != nil {
-		return errors.New("更新笔记失败：" + err.Error())
+		return Internal("更新笔记失败", err)
+	}
+
+	return nil
+}
+
+// BatchNotes implements NoteService.
+func (n *noteService) BatchNotes(action string, ids []int64, tags []string) ([]BatchResult, error) {
+	switch action {
+	case BatchDelete, BatchRestore, BatchHardDelete, BatchAddTag, BatchReindex:
+	default:
+		return nil, Validation(common.CodeBatchAction, action)
+	}
+	if len(ids) == 0 {
+		return nil, Validation(common.CodeBatchEmpty)
+	}
+	if len(ids) > maxBatchSize {
+		return nil, Validation(common.CodeBatchTooMany, maxBatchSize)
+	}
+	if action == BatchAddTag {
+		tags = normalizeTags(tags)
+		if len(tags) == 0 {
+			return nil, Validation(common.CodeTagRequired)
+		}
+	}
+
+	var results []BatchResult
+	err := n.repo.Transaction(func(repo repository.NoteRepository) error {
+		results = make([]BatchResult, 0, len(ids))
+		notes, err := repo.FindByIDs(ids)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int64]model.Note, len(notes))
+		for _, note := range notes {
+			byID[note.ID] = note
+		}
+
+		okIDs := make([]int64, 0, len(ids))
+		seen := make(map[int64]struct{}, len(ids))
+		for _, id := range ids {
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+			if e := checkBatchTarget(action, id, byID); e != nil {
+				results = append(results, BatchResult{ID: id, Code: e.Code, Error: e.Error()})
+				continue
+			}
+			okIDs = append(okIDs, id)
+			results = append(results, BatchResult{ID: id, OK: true})
+		}
+		if len(okIDs) == 0 {
+			return nil
+		}
+
+		switch action {
+		case BatchDelete:
+			return repo.DeleteByIDs(okIDs)
+		case BatchRestore:
+			return repo.RestoreByIDs(okIDs)
+		case BatchHardDelete:
+			return repo.HardDeleteByIDs(okIDs)
+		case BatchAddTag:
+			return repo.AddTags(okIDs, tags)
+		}
+		// reindex 只需校验笔记存在，索引由调用方完成
+		return nil
+	})
+	if err != nil {
+		return nil, Internal("批量操作失败", err)
+	}
+	return results, nil
+}
+
+// checkBatchTarget 校验笔记是否可执行该操作，不可执行时返回原因
+func checkBatchTarget(action string, id int64, byID map[int64]model.Note) *Error {
+	if id <= 0 {
+		return Validation(common.CodeInvalidID)
+	}
+	note, ok := byID[id]
+	if !ok {
+		return NotFound(common.CodeNoteNotFound)
+	}
+	switch action {
+	case BatchRestore:
+		if note.IsDeleted == 0 {
+			return Conflict(common.CodeNoteNotInTrash)
+		}
+	case BatchDelete, BatchAddTag, BatchReindex:
+		if note.IsDeleted != 0 {
+			return Conflict(common.CodeNoteInTrash)
+		}
 	}
+	return nil
+}
 
+// checkTarget 单条笔记版本的 checkBatchTarget，笔记不存在或状态不符时返回对应业务错误
+func (n *noteService) checkTarget(action string, id int64) error {
+	if id <= 0 {
+		return Validation(common.CodeInvalidID)
+	}
+	notes, err := n.repo.FindByIDs([]int64{id})
+	if err != nil {
+		return Internal("查询笔记失败", err)
+	}
+	byID := make(map[int64]model.Note, len(notes))
+	for _, note := range notes {
+		byID[note.ID] = note
+	}
+	if e := checkBatchTarget(action, id, byID); e != nil {
+		return e
+	}
 	return nil
 }
 
-func NewNoteService(repo repository.NoteRepository) NoteService {
-	return &noteService{repo: repo}
+// normalizeTags 去除空白与重复标签
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || utf8.RuneCountInString(t) > 64 {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (n *noteService) ListByIDs(ids []int64) ([]model.Note, error) {
+	list, err := n.repo.FindByIDs(ids)
+	if err != nil {
+		return nil, Internal("查询笔记失败", err)
+	}
+	out := make([]model.Note, 0, len(list))
+	for _, note := range list {
+		if note.IsDeleted == 0 {
+			out = append(out, note)
+		}
+	}
+	return out, nil
+}
+
+// NewNoteService 创建笔记服务，trashRetention 为回收站保留期（<=0 表示永久保留）
+func NewNoteService(repo repository.NoteRepository, trashRetention time.Duration) NoteService {
+	return &noteService{repo: repo, trashRetention: trashRetention}
 }
 
 func (n *noteService) SetNoteTimes(id int64, createdAt, updatedAt time.Time) error {
 	if id <= 0 {
-		return errors.New("笔记ID不合法(必须大于0)")
+		return Validation(common.CodeInvalidID)
 	}
 	return n.repo.UpdateTimes(id, createdAt, updatedAt)
 }
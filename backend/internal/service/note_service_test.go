@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"note-system/internal/common"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 在临时目录中打开 SQLite 数据库并执行全部迁移
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := repository.OpenDB(repository.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if _, err := repository.Migrate(db); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createNotes(t *testing.T, svc NoteService, titles ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, len(titles))
+	for _, title := range titles {
+		note, err := svc.CreateNote(title, "正文 "+title)
+		if err != nil {
+			t.Fatalf("创建笔记失败: %v", err)
+		}
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+// errCode 取业务错误码，非 *Error 时返回 0
+func errCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return 0
+}
+
+func TestBatchNotesValidation(t *testing.T) {
+	svc := NewNoteService(repository.NewNoteRepo(openTestDB(t)), 0)
+	tooMany := make([]int64, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+	cases := []struct {
+		name   string
+		action string
+		ids    []int64
+		tags   []string
+		code   int
+	}{
+		{"未知操作", "archive", []int64{1}, nil, common.CodeBatchAction},
+		{"ID为空", BatchDelete, nil, nil, common.CodeBatchEmpty},
+		{"数量超限", BatchDelete, tooMany, nil, common.CodeBatchTooMany},
+		{"标签全为空白", BatchAddTag, []int64{1}, []string{" ", ""}, common.CodeTagRequired},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := svc.BatchNotes(c.action, c.ids, c.tags)
+			if code := errCode(err); code != c.code {
+				t.Fatalf("错误码 %d, want %d (err=%v)", code, c.code, err)
+			}
+		})
+	}
+}
+
+func TestBatchNotesPerItemResults(t *testing.T) {
+	repo := repository.NewNoteRepo(openTestDB(t))
+	svc := NewNoteService(repo, 0)
+	ids := createNotes(t, svc, "a", "b", "c")
+	if err := svc.DeleteNote(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复的ID只处理一次；不存在、不合法与已在回收站的笔记逐条报告，其余照常删除
+	results, err := svc.BatchNotes(BatchDelete, []int64{ids[0], ids[0], 999, -1, ids[2], ids[1]}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BatchResult{
+		{ID: ids[0], OK: true},
+		{ID: 999, Code: common.CodeNoteNotFound},
+		{ID: -1, Code: common.CodeInvalidID},
+		{ID: ids[2], Code: common.CodeNoteInTrash},
+		{ID: ids[1], OK: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d 条结果: %+v", len(results), results)
+	}
+	for i, r := range results {
+		if r.ID != want[i].ID || r.OK != want[i].OK || r.Code != want[i].Code {
+			t.Errorf("第 %d 条 got %+v, want %+v", i, r, want[i])
+		}
+	}
+	if n, _ := repo.Count(false); n != 0 {
+		t.Fatalf("正常笔记剩余 %d 条", n)
+	}
+	if n, _ := repo.Count(true); n != 3 {
+		t.Fatalf("回收站中有 %d 条，want 3", n)
+	}
+
+	results, err = svc.BatchNotes(BatchRestore, []int64{ids[0], ids[1]}, nil)
+	if err != nil || !results[0].OK || !results[1].OK {
+		t.Fatalf("恢复失败: %+v, %v", results, err)
+	}
+	results, err = svc.BatchNotes(BatchRestore, []int64{ids[0]}, nil)
+	if err != nil || results[0].Code != common.CodeNoteNotInTrash {
+		t.Fatalf("恢复未删除的笔记: %+v, %v", results, err)
+	}
+}
+
+func TestBatchNotesAddTag(t *testing.T) {
+	repo := repository.NewNoteRepo(openTestDB(t))
+	svc := NewNoteService(repo, 0)
+	ids := createNotes(t, svc, "a", "b")
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.BatchNotes(BatchAddTag, ids, []string{" go ", "go", "数据库"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags, err := repo.TagsByNotes(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if got := tags[id]; len(got) != 2 || got[0] != "go" || got[1] != "数据库" {
+			t.Fatalf("笔记 %d 的标签 %v", id, got)
+		}
+	}
+}
+
+func TestBatchNotesHardDelete(t *testing.T) {
+	db := openTestDB(t)
+	repo := repository.NewNoteRepo(db)
+	svc := NewNoteService(repo, 0)
+	ids := createNotes(t, svc, "a", "b")
+
+	results, err := svc.BatchNotes(BatchHardDelete, ids, nil)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("%+v, %v", results, err)
+	}
+	var left int64
+	db.Model(&model.Note{}).Count(&left)
+	if left != 0 {
+		t.Fatalf("物理删除后仍有 %d 条笔记", left)
+	}
+
+	// 事务内出错时整体回滚，返回内部错误；预期内的 SQL 错误不输出日志
+	ids = createNotes(t, svc, "c")
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := db.Exec("DROP TABLE note_tags").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.BatchNotes(BatchHardDelete, ids, nil); err == nil {
+		t.Fatal("删除标签失败时应返回错误")
+	}
+	db.Model(&model.Note{}).Count(&left)
+	if left != 1 {
+		t.Fatalf("回滚后应保留 1 条笔记，实际 %d 条", left)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"note-system/internal/common"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"strings"
+	"time"
+)
+
+// 每页条数的默认值与上限
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListOptions 列表查询参数
+type ListOptions struct {
+	// Cursor 上一页返回的 next_cursor，为空表示第一页
+	Cursor string
+	Size   int
+	// Sort 排序字段：updated_at（默认）、created_at、title
+	Sort string
+	// Order asc 或 desc；时间字段默认 desc，标题默认 asc
+	Order string
+	// WithTotal 为 true 时额外统计总条数
+	WithTotal bool
+	// Fields 返回的字段，为空时使用默认字段（不含正文）
+	Fields []string
+}
+
+// listFields 列表可选字段及其依赖的列；tags 来自标签表，purge_at 由删除时间与保留期算出
+var listFields = map[string][]string{
+	"id":         {"id"},
+	"title":      {"title"},
+	"content":    {"content"},
+	"excerpt":    {"excerpt"},
+	"word_count": {"word_count"},
+	"summary":    {"summary"},
+	// suggested_tags 与 suggested_title 是 LLM 给出的建议，不在默认字段中
+	"suggested_title": {"suggested_title"},
+	"suggested_tags":  {"suggested_tags"},
+	"source_url":      {"source_url"},
+	"created_at":      {"created_at"},
+	"updated_at":      {"updated_at"},
+	"deleted_at":      {"deleted_at"},
+	"purge_at":        {"deleted_at", "updated_at"},
+	"tags":            nil,
+}
+
+// 列表默认字段：只返回侧边栏与卡片需要的内容，正文需通过 fields=content 显式请求
+var (
+	defaultListFields  = []string{"id", "title", "excerpt", "summary", "word_count", "tags", "created_at", "updated_at"}
+	defaultTrashFields = []string{"id", "title", "excerpt", "summary", "word_count", "tags", "created_at", "updated_at", "deleted_at", "purge_at"}
+)
+
+// ParseFields 解析逗号分隔的字段列表
+func ParseFields(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// NotePage 一页笔记；HasMore 为 true 时用 NextCursor 请求下一页。
+// 序列化时 List 中每条笔记只输出 fields 中的字段
+type NotePage struct {
+	List       []model.Note
+	NextCursor string
+	HasMore    bool
+	Total      *int64
+	fields     []string
+}
+
+func (p *NotePage) MarshalJSON() ([]byte, error) {
+	items := make([]map[string]interface{}, 0, len(p.List))
+	for i := range p.List {
+		item := make(map[string]interface{}, len(p.fields))
+		for _, f := range p.fields {
+			item[f] = noteField(&p.List[i], f)
+		}
+		items = append(items, item)
+	}
+	return json.Marshal(struct {
+		List       []map[string]interface{} `json:"list"`
+		NextCursor string                   `json:"next_cursor,omitempty"`
+		HasMore    bool                     `json:"has_more"`
+		Total      *int64                   `json:"total,omitempty"`
+	}{items, p.NextCursor, p.HasMore, p.Total})
+}
+
+func noteField(n *model.Note, field string) interface{} {
+	switch field {
+	case "id":
+		return n.ID
+	case "title":
+		return n.Title
+	case "content":
+		return n.Content
+	case "excerpt":
+		return n.Excerpt
+	case "word_count":
+		return n.WordCount
+	case "summary":
+		return n.Summary
+	case "suggested_title":
+		return n.SuggestedTitle
+	case "suggested_tags":
+		if n.SuggestedTags == nil {
+			return []string{}
+		}
+		return n.SuggestedTags
+	case "source_url":
+		return n.SourceURL
+	case "created_at":
+		return n.CreatedAt
+	case "updated_at":
+		return n.UpdatedAt
+	case "deleted_at":
+		return n.DeletedAt
+	case "purge_at":
+		return n.PurgeAt
+	case "tags":
+		if n.Tags == nil {
+			return []string{}
+		}
+		return n.Tags
+	}
+	return nil
+}
+
+// columns 校验字段并返回需要查询的列（总是包含 id 与排序字段）
+func (o ListOptions) columns(deleted bool, sort string) ([]string, []string, error) {
+	fields := o.Fields
+	if len(fields) == 0 {
+		fields = defaultListFields
+		if deleted {
+			fields = defaultTrashFields
+		}
+	}
+	cols := []string{"id", sort}
+	seen := map[string]bool{"id": true, sort: true}
+	for _, f := range fields {
+		deps, ok := listFields[f]
+		if !ok {
+			return nil, nil, Validation(common.CodeInvalidParam, "fields: "+f)
+		}
+		for _, c := range deps {
+			if !seen[c] {
+				seen[c] = true
+				cols = append(cols, c)
+			}
+		}
+	}
+	return fields, cols, nil
+}
+
+// pageCursor 游标内容，记录生成时的排序方式，防止换了排序后继续翻页
+type pageCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"n,omitempty"`
+	ID    int64     `json:"i"`
+}
+
+func encodeCursor(sort string, desc bool, c repository.Cursor) string {
+	b, _ := json.Marshal(pageCursor{Sort: sort, Desc: desc, Time: c.Time, Title: c.Title, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// query 校验参数并转换为仓储层的键集查询条件
+func (o ListOptions) query() (repository.ListQuery, error) {
+	q := repository.ListQuery{Sort: o.Sort, Limit: o.Size}
+	switch q.Sort {
+	case "":
+		q.Sort = repository.SortUpdatedAt
+	case repository.SortUpdatedAt, repository.SortCreatedAt, repository.SortTitle:
+	default:
+		return q, Validation(common.CodeInvalidSort, o.Sort)
+	}
+	switch o.Order {
+	case "":
+		q.Desc = q.Sort != repository.SortTitle
+	case "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, Validation(common.CodeInvalidSort, o.Order)
+	}
+	// 未指定时取默认值，超过上限时按上限返回
+	if q.Limit < 1 {
+		q.Limit = defaultPageSize
+	}
+	q.Limit = min(q.Limit, maxPageSize)
+	if o.Cursor != "" {
+		c, err := decodeCursor(o.Cursor)
+		if err != nil || c.Sort != q.Sort || c.Desc != q.Desc {
+			return q, Validation(common.CodeInvalidCursor)
+		}
+		q.After = &repository.Cursor{Time: c.Time, Title: c.Title, ID: c.ID}
+	}
+	return q, nil
+}
+
+func containsField(fields []string, f string) bool {
+	for _, x := range fields {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestListOptionsPageSize(t *testing.T) {
+	cases := []struct {
+		size, want int
+	}{
+		{0, defaultPageSize},
+		{-5, defaultPageSize},
+		{1, 1},
+		{maxPageSize, maxPageSize},
+		// 超过上限时按上限返回，而不是退回默认值
+		{maxPageSize + 1, maxPageSize},
+		{1000, maxPageSize},
+	}
+	for _, c := range cases {
+		q, err := ListOptions{Size: c.size}.query()
+		if err != nil {
+			t.Fatalf("size %d: %v", c.size, err)
+		}
+		if q.Limit != c.want {
+			t.Fatalf("size %d: limit = %d, want %d", c.size, q.Limit, c.want)
+		}
+	}
+}
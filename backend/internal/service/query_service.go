@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"note-system/config"
+	"note-system/internal/llm"
+	"note-system/internal/logging"
+	"strings"
+)
+
+const (
+	// 参与改写的对话历史条数与单条长度上限（字）
+	maxHistoryMessages  = 6
+	historyMessageRunes = 500
+	// 改写与假设性回答都很短，限制 token 数以控制检索前的等待时间
+	rewriteMaxTokens = 256
+	hydeMaxTokens    = 320
+)
+
+// Expansion 问题改写结果
+type Expansion struct {
+	// Question 结合对话历史补全后的独立问题，未改写时为原问题
+	Question string `json:"question"`
+	// Queries 独立问题与各个问法，已去重，可展示给用户
+	Queries []string `json:"queries"`
+	// HyDE 假设性回答，只用于检索，不返回给用户
+	HyDE string `json:"-"`
+}
+
+// SearchTexts 参与检索的全部文本：Queries 之后附加假设性回答
+func (e *Expansion) SearchTexts() []string {
+	return dedupeQueries(append(append([]string{}, e.Queries...), e.HyDE))
+}
+
+// QueryExpander 问答检索前调用 LLM 改写问题（需开启 rag.query_rewrite）：
+// 结合对话历史得到独立问题，再生成若干问法与可选的 HyDE 假设性回答，分别向量化后合并检索结果
+type QueryExpander struct {
+	llm llm.ChatClient
+	cfg *config.Holder
+}
+
+func NewQueryExpander(chat llm.ChatClient, cfg *config.Holder) *QueryExpander {
+	return &QueryExpander{llm: chat, cfg: cfg}
+}
+
+// Expand 改写问题；未开启、未配置 LLM 或调用失败时退回原问题，不返回错误
+func (e *QueryExpander) Expand(ctx context.Context, question string, history []llm.Message) *Expansion {
+	exp := &Expansion{Question: question, Queries: []string{question}}
+	cfg := e.cfg.Get()
+	if !cfg.Rag.QueryRewrite || !e.llm.Enabled() {
+		return exp
+	}
+	data := llm.QueryData{Question: question, History: trimHistory(history), Variants: cfg.Rag.QueryVariants}
+	rewritten, variants, err := e.rewrite(ctx, cfg.LLM.PromptDir, data)
+	logging.WarnIf(ctx, err, "问题改写失败，使用原问题检索")
+	if err == nil && rewritten != "" {
+		exp.Question = rewritten
+	}
+	exp.Queries = dedupeQueries(append([]string{exp.Question, question}, variants...))
+	if cfg.Rag.HyDE {
+		answer, err := e.hyde(ctx, cfg.LLM.PromptDir, exp.Question)
+		logging.WarnIf(ctx, err, "生成假设性回答失败")
+		exp.HyDE = answer
+	}
+	return exp
+}
+
+func (e *QueryExpander) rewrite(ctx context.Context, dir string, data llm.QueryData) (string, []string, error) {
+	msgs, err := llm.Render(dir, llm.PromptRewrite, data)
+	if err != nil {
+		return "", nil, err
+	}
+	temperature := 0.0
+	resp, err := e.llm.Chat(ctx, llm.ChatRequest{Op: llm.PromptRewrite, Messages: msgs, MaxTokens: rewriteMaxTokens, Temperature: &temperature, JSON: true})
+	if err != nil {
+		return "", nil, err
+	}
+	var out struct {
+		Question string   `json:"question"`
+		Queries  []string `json:"queries"`
+	}
+	text := resp.Content
+	if i, j := strings.Index(text, "{"), strings.LastIndex(text, "}"); i >= 0 && j > i {
+		text = text[i : j+1]
+	}
+	if err := json.Unmarshal([]byte(text), &out); err != nil {
+		return "", nil, err
+	}
+	if len(out.Queries) > data.Variants {
+		out.Queries = out.Queries[:data.Variants]
+	}
+	return strings.TrimSpace(out.Question), out.Queries, nil
+}
+
+func (e *QueryExpander) hyde(ctx context.Context, dir, question string) (string, error) {
+	msgs, err := llm.Render(dir, llm.PromptHyDE, llm.QueryData{Question: question})
+	if err != nil {
+		return "", err
+	}
+	resp, err := e.llm.Chat(ctx, llm.ChatRequest{Op: llm.PromptHyDE, Messages: msgs, MaxTokens: hydeMaxTokens})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// trimHistory 只保留最近几轮用户与助手的对话，过长的消息截断
+func trimHistory(history []llm.Message) []llm.Message {
+	out := make([]llm.Message, 0, len(history))
+	for _, m := range history {
+		if (m.Role != llm.RoleUser && m.Role != llm.RoleAssistant) || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		out = append(out, llm.Message{Role: m.Role, Content: truncateRunes(strings.TrimSpace(m.Content), historyMessageRunes)})
+	}
+	if len(out) > maxHistoryMessages {
+		out = out[len(out)-maxHistoryMessages:]
+	}
+	return out
+}
+
+func dedupeQueries(queries []string) []string {
+	seen := make(map[string]bool, len(queries))
+	out := make([]string, 0, len(queries))
+	for _, q := range queries {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] {
+			continue
+		}
+		seen[q] = true
+		out = append(out, q)
+	}
+	return out
+}
@@ -1,84 +1,315 @@
 package service
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
+	"note-system/config"
+	"note-system/internal/extract"
+	"note-system/internal/metrics"
 	"note-system/internal/model"
 	"note-system/internal/rag"
-	"os"
 
 	"gorm.io/gorm"
 )
 
 type RAGService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg *config.Holder
 }
 
-func NewRAGService(db *gorm.DB) *RAGService { return &RAGService{db: db} }
+// NewRAGService 向量相关配置每次调用时从 cfg 读取，热加载后立即生效
+func NewRAGService(db *gorm.DB, cfg *config.Holder) *RAGService {
+	return &RAGService{db: db, cfg: cfg}
+}
+
+func (r *RAGService) IndexNote(ctx context.Context, note *model.Note) error {
+	return r.IndexNotes(ctx, []*model.Note{note})
+}
 
-func (r *RAGService) IndexNote(note *model.Note) error {
-	cands := rag.SplitMarkdown(note.Content)
-	texts := make([]string, 0, len(cands))
-	ids := make([]string, 0, len(cands))
+// IndexNotes 切分多条笔记并一次性生成向量、写入 Pinecone，再删除旧版本正文中已不存在的片段
+func (r *RAGService) IndexNotes(ctx context.Context, notes []*model.Note) error {
+	frags := make([]*model.Fragment, 0)
 	metas := make(map[string]map[string]interface{})
-	for i, c := range cands {
-		fid := fragID(note.ID, i, c.Content)
-		f := &model.Fragment{NoteID: note.ID, FragID: fid, Content: c.Content, IsCode: c.IsCode}
-		_ = r.db.Where("frag_id = ?", fid).Delete(&model.Fragment{}).Error
-		if err := r.db.Create(f).Error; err != nil {
+	noteIDs := make([]int64, 0, len(notes))
+	for _, note := range notes {
+		if note == nil {
 			continue
 		}
-		texts = append(texts, c.Content)
-		ids = append(ids, fid)
-		metas[fid] = map[string]interface{}{"note_id": note.ID, "frag_id": fid, "title": note.Title, "content": c.Content}
+		noteIDs = append(noteIDs, note.ID)
+		cands := rag.SplitMarkdown(note.Content)
+		for i, c := range cands {
+			fid := fragID(note.ID, i, c.Content)
+			frags = append(frags, &model.Fragment{NoteID: note.ID, FragID: fid, Content: c.Content, IsCode: c.IsCode, Source: model.FragSourceNote})
+			metas[fid] = map[string]interface{}{"note_id": note.ID, "frag_id": fid, "title": note.Title, "content": c.Content, "source": model.FragSourceNote}
+			for k, v := range summaryMeta(note) {
+				metas[fid][k] = v
+			}
+		}
+	}
+	if err := r.embedAndSave(ctx, frags, metas); err != nil {
+		return err
+	}
+	keep := make([]string, 0, len(frags))
+	for _, f := range frags {
+		keep = append(keep, f.FragID)
+	}
+	return r.pruneNoteFragments(ctx, noteIDs, keep)
+}
+
+// pruneNoteFragments 删除笔记中不在 keep 里的正文片段及其向量，附件片段不受影响
+func (r *RAGService) pruneNoteFragments(ctx context.Context, noteIDs []int64, keep []string) error {
+	if r.db == nil || len(noteIDs) == 0 {
+		return nil
+	}
+	q := r.db.WithContext(ctx).Model(&model.Fragment{}).Where("note_id IN ? AND source = ?", noteIDs, model.FragSourceNote)
+	if len(keep) > 0 {
+		q = q.Where("frag_id NOT IN ?", keep)
+	}
+	var stale []model.Fragment
+	if err := q.Select("id", "frag_id").Find(&stale).Error; err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(stale))
+	fids := make([]string, 0, len(stale))
+	for _, f := range stale {
+		ids = append(ids, f.ID)
+		fids = append(fids, f.FragID)
+	}
+	if err := rag.PineconeDeleteByIDs(ctx, r.cfg.Get().Rag, fids); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.Fragment{}).Error
+}
+
+// IndexAttachment 将附件抽取出的文本按页切分为片段，挂在所属笔记下并写入向量库
+func (r *RAGService) IndexAttachment(ctx context.Context, note *model.Note, att *model.Attachment, pages []extract.Page) error {
+	if note == nil || att == nil {
+		return nil
+	}
+	if err := r.DeleteAttachmentFragments(ctx, att.ID); err != nil {
+		return err
+	}
+	frags := make([]*model.Fragment, 0)
+	metas := make(map[string]map[string]interface{})
+	for _, p := range pages {
+		for i, c := range rag.SplitMarkdown(p.Text) {
+			// 附件片段的ID带上附件与页码，避免与正文或其他附件的相同文本冲突
+			fid := fragID(note.ID, i, fmt.Sprintf("attachment:%d:%d:%s", att.ID, p.Number, c.Content))
+			frags = append(frags, &model.Fragment{
+				NoteID: note.ID, FragID: fid, Content: c.Content, IsCode: c.IsCode,
+				Source: model.FragSourceAttachment, AttachmentID: att.ID, Page: p.Number,
+			})
+			metas[fid] = map[string]interface{}{
+				"note_id": note.ID, "frag_id": fid, "title": note.Title, "content": c.Content,
+				"source": model.FragSourceAttachment, "attachment_id": att.ID, "file_name": att.FileName, "page": p.Number,
+			}
+		}
+	}
+	return r.embedAndSave(ctx, frags, metas)
+}
+
+// UpdateNoteSummary 把笔记的 LLM 摘要与建议标签写入其全部片段（含附件片段）的向量元数据
+func (r *RAGService) UpdateNoteSummary(ctx context.Context, note *model.Note) error {
+	meta := summaryMeta(note)
+	if len(meta) == 0 {
+		return nil
+	}
+	ids, err := r.fragIDsByNoteIDs(ctx, []int64{note.ID})
+	if err != nil {
+		return err
+	}
+	return rag.PineconeSetMetadata(ctx, r.cfg.Get().Rag, ids, meta)
+}
+
+// summaryMeta 摘要相关的向量元数据，摘要已过期时不写入
+func summaryMeta(note *model.Note) map[string]interface{} {
+	if note.Summary == "" || !note.SummaryFresh() {
+		return nil
 	}
-	if len(texts) == 0 {
+	meta := map[string]interface{}{"summary": note.Summary}
+	if len(note.SuggestedTags) > 0 {
+		meta["tags"] = note.SuggestedTags
+	}
+	return meta
+}
+
+// DeleteAttachmentFragments 删除附件对应的片段与向量
+func (r *RAGService) DeleteAttachmentFragments(ctx context.Context, attachmentID int64) error {
+	if r.db == nil || attachmentID <= 0 {
 		return nil
 	}
-	vecs, err := rag.EmbedBatch(texts)
-	if err != nil || vecs == nil {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&model.Fragment{}).Where("attachment_id = ?", attachmentID).Pluck("frag_id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
 		return nil
 	}
+	if err := rag.PineconeDeleteByIDs(ctx, r.cfg.Get().Rag, ids); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Where("attachment_id = ?", attachmentID).Delete(&model.Fragment{}).Error
+}
+
+// embedAndSave 先生成向量并写入 Pinecone，成功后再在一个事务中落库片段。
+// ctx 被取消时中途退出，不会留下没有向量的片段；已写入的向量在下次索引时按相同ID覆盖
+func (r *RAGService) embedAndSave(ctx context.Context, frags []*model.Fragment, metas map[string]map[string]interface{}) error {
+	if len(frags) == 0 {
+		return nil
+	}
+	metrics.IndexingInflight.Inc()
+	defer metrics.IndexingInflight.Dec()
+	texts := make([]string, len(frags))
+	for i, f := range frags {
+		texts[i] = f.Content
+	}
+	cfg := r.cfg.Get().Rag
+	vecs, err := rag.EmbedBatch(ctx, cfg, texts)
+	if err != nil {
+		return err
+	}
 	vmap := make(map[string][]float32)
-	for i, id := range ids {
+	for i, f := range frags {
 		if i < len(vecs) {
-			vmap[id] = vecs[i]
+			vmap[f.FragID] = vecs[i]
 		}
 	}
-	_ = rag.PineconeUpsert(getEnv("PINECONE_INDEX"), vmap, metas)
-	return nil
+	if err := rag.PineconeUpsert(ctx, cfg, vmap, metas); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, f := range frags {
+			if err := tx.Where("frag_id = ?", f.FragID).Delete(&model.Fragment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(f).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (r *RAGService) PurgeFragments() error {
+// CountFragmentsBySource 按来源（正文/附件）统计片段数
+func (r *RAGService) CountFragmentsBySource() (map[string]int64, error) {
+	var rows []struct {
+		Source string
+		N      int64
+	}
+	if err := r.db.Model(&model.Fragment{}).Select("source, COUNT(*) AS n").Group("source").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := map[string]int64{model.FragSourceNote: 0, model.FragSourceAttachment: 0}
+	for _, row := range rows {
+		out[row.Source] = row.N
+	}
+	return out, nil
+}
+
+func (r *RAGService) PurgeFragments(ctx context.Context) error {
 	if r.db == nil {
 		return nil
 	}
-	return r.db.Exec("DELETE FROM fragments").Error
+	return r.db.WithContext(ctx).Exec("DELETE FROM fragments").Error
 }
 
-func (r *RAGService) DeleteVectorsByNoteID(noteID int64) error {
-	if r.db == nil || noteID <= 0 {
+func (r *RAGService) DeleteVectorsByNoteID(ctx context.Context, noteID int64) error {
+	if noteID <= 0 {
+		return nil
+	}
+	return r.DeleteVectorsByNoteIDs(ctx, []int64{noteID})
+}
+
+// DeleteVectorsByNoteIDs 一次性删除多条笔记在 Pinecone 中的向量
+func (r *RAGService) DeleteVectorsByNoteIDs(ctx context.Context, noteIDs []int64) error {
+	ids, err := r.fragIDsByNoteIDs(ctx, noteIDs)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return rag.PineconeDeleteByIDs(ctx, r.cfg.Get().Rag, ids)
+}
+
+// PurgeNotes 笔记被物理删除后，清理其向量与片段记录
+func (r *RAGService) PurgeNotes(ctx context.Context, noteIDs []int64) error {
+	if err := r.DeleteVectorsByNoteIDs(ctx, noteIDs); err != nil {
+		return err
+	}
+	if r.db == nil || len(noteIDs) == 0 {
 		return nil
 	}
+	return r.db.WithContext(ctx).Where("note_id IN ?", noteIDs).Delete(&model.Fragment{}).Error
+}
+
+// NoteFragments 查询笔记的全部片段（含附件片段），按写入顺序排列
+func (r *RAGService) NoteFragments(ctx context.Context, noteID int64) ([]model.Fragment, error) {
 	var frags []model.Fragment
-	if err := r.db.Model(&model.Fragment{}).Where("note_id = ?", noteID).Find(&frags).Error; err != nil {
+	if r.db == nil || noteID <= 0 {
+		return frags, nil
+	}
+	err := r.db.WithContext(ctx).Where("note_id = ?", noteID).Order("id ASC").Find(&frags).Error
+	return frags, err
+}
+
+// FragmentIDsByNote 按笔记分组查询全部片段ID
+func (r *RAGService) FragmentIDsByNote(ctx context.Context) (map[int64][]string, error) {
+	out := make(map[int64][]string)
+	if r.db == nil {
+		return out, nil
+	}
+	var rows []model.Fragment
+	if err := r.db.WithContext(ctx).Model(&model.Fragment{}).Select("note_id", "frag_id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range rows {
+		if f.FragID != "" {
+			out[f.NoteID] = append(out[f.NoteID], f.FragID)
+		}
+	}
+	return out, nil
+}
+
+// MoveAttachmentFragments 附件改挂到 note 下后，同步其片段的所属笔记与向量元数据，不重新生成向量
+func (r *RAGService) MoveAttachmentFragments(ctx context.Context, note *model.Note, attachmentIDs []int64) error {
+	if r.db == nil || note == nil || len(attachmentIDs) == 0 {
+		return nil
+	}
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&model.Fragment{}).Where("attachment_id IN ?", attachmentIDs).Pluck("frag_id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Model(&model.Fragment{}).Where("attachment_id IN ?", attachmentIDs).Update("note_id", note.ID).Error; err != nil {
 		return err
 	}
+	return rag.PineconeSetMetadata(ctx, r.cfg.Get().Rag, ids, map[string]interface{}{"note_id": note.ID, "title": note.Title})
+}
+
+func (r *RAGService) fragIDsByNoteIDs(ctx context.Context, noteIDs []int64) ([]string, error) {
+	if r.db == nil || len(noteIDs) == 0 {
+		return nil, nil
+	}
+	var frags []model.Fragment
+	if err := r.db.WithContext(ctx).Model(&model.Fragment{}).Where("note_id IN ?", noteIDs).Find(&frags).Error; err != nil {
+		return nil, err
+	}
 	ids := make([]string, 0, len(frags))
 	for _, f := range frags {
 		if f.FragID != "" {
 			ids = append(ids, f.FragID)
 		}
 	}
-	if len(ids) == 0 {
-		return nil
-	}
-	return rag.PineconeDeleteByIDs(ids)
+	return ids, nil
 }
 
+// fragID 由所属笔记与内容生成片段ID：不同笔记中的相同段落各有一条片段，同一笔记重新索引时ID不变
 func fragID(noteID int64, i int, content string) string {
-	h := sha1.Sum([]byte(content))
+	h := sha1.Sum([]byte(fmt.Sprintf("%d:%s", noteID, content)))
 	return hex.EncodeToString(h[:])
 }
-
-func getEnv(key string) string { return os.Getenv(key) }
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"note-system/config"
+	"note-system/internal/common"
+	"note-system/internal/model"
+	"note-system/internal/rag"
+	"note-system/internal/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRelatedLimit = 5
+	MaxRelatedLimit     = 20
+	// relatedCandidates 用质心检索的片段数，分组后一般能覆盖 MaxRelatedLimit 条笔记
+	relatedCandidates = 100
+	// relatedPairs 每条相关笔记返回的匹配片段对数
+	relatedPairs = 3
+	// relatedCacheSize 缓存的笔记数上限，超出时随机淘汰一条
+	relatedCacheSize = 1024
+)
+
+// FragmentPair 当前笔记与相关笔记之间最相近的一对片段
+type FragmentPair struct {
+	// SourceFragID 当前笔记中与 Target 最相近的片段，向量库未返回向量值时为空
+	SourceFragID string `json:"source_frag_id,omitempty"`
+	Source       string `json:"source,omitempty"`
+	TargetFragID string `json:"target_frag_id"`
+	Target       string `json:"target"`
+	// Score 两条片段的余弦相似度；没有向量值时为命中片段与质心的相似度
+	Score float32 `json:"score"`
+}
+
+// RelatedNote 一条相关笔记，Score 为其片段与当前笔记质心的最高相似度
+type RelatedNote struct {
+	ID        int64          `json:"id"`
+	Title     string         `json:"title"`
+	Excerpt   string         `json:"excerpt"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Score     float32        `json:"score"`
+	Pairs     []FragmentPair `json:"pairs"`
+}
+
+// relatedEntry 缓存的推荐结果及计算时各笔记的版本（UpdatedAt）
+type relatedEntry struct {
+	version  time.Time
+	versions map[int64]time.Time
+	list     []RelatedNote
+}
+
+// RelatedService 相关笔记推荐：取笔记全部片段向量的质心，在向量库中检索其他笔记的片段并按笔记分组。
+// 结果按笔记缓存，当前笔记或任一相关笔记更新、删除后失效
+type RelatedService struct {
+	repo repository.NoteRepository
+	rag  *RAGService
+	cfg  *config.Holder
+
+	mu    sync.Mutex
+	cache map[int64]*relatedEntry
+}
+
+func NewRelatedService(repo repository.NoteRepository, rag *RAGService, cfg *config.Holder) *RelatedService {
+	return &RelatedService{repo: repo, rag: rag, cfg: cfg, cache: make(map[int64]*relatedEntry)}
+}
+
+// Related 返回与笔记 id 最相似的至多 limit 条笔记
+func (s *RelatedService) Related(ctx context.Context, id int64, limit int) ([]RelatedNote, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	if limit <= 0 {
+		limit = DefaultRelatedLimit
+	}
+	limit = min(limit, MaxRelatedLimit)
+	note, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, dbError("查询笔记失败", err, common.CodeNoteNotFound)
+	}
+	cfg := s.cfg.Get().Rag
+	if !rag.PineconeEnabled(cfg) {
+		return nil, Unavailable(common.CodeVectorDisabled)
+	}
+	list, ok, err := s.cached(note)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if list, err = s.compute(ctx, cfg, note); err != nil {
+			return nil, err
+		}
+	}
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// cached 缓存命中且当前笔记与各相关笔记都未变化时返回缓存结果
+func (s *RelatedService) cached(note *model.Note) ([]RelatedNote, bool, error) {
+	s.mu.Lock()
+	entry := s.cache[note.ID]
+	s.mu.Unlock()
+	if entry == nil || !entry.version.Equal(note.UpdatedAt) {
+		return nil, false, nil
+	}
+	ids := make([]int64, 0, len(entry.versions))
+	for nid := range entry.versions {
+		ids = append(ids, nid)
+	}
+	notes, err := s.repo.FindByIDs(ids)
+	if err != nil {
+		return nil, false, Internal("查询相关笔记失败", err)
+	}
+	fresh := 0
+	for _, n := range notes {
+		if v, ok := entry.versions[n.ID]; ok && n.IsDeleted == 0 && v.Equal(n.UpdatedAt) {
+			fresh++
+		}
+	}
+	return entry.list, fresh == len(entry.versions), nil
+}
+
+func (s *RelatedService) compute(ctx context.Context, cfg config.RagConfig, note *model.Note) ([]RelatedNote, error) {
+	frags, err := s.rag.NoteFragments(ctx, note.ID)
+	if err != nil {
+		return nil, Internal("查询笔记片段失败", err)
+	}
+	ids := make([]string, 0, len(frags))
+	for _, f := range frags {
+		ids = append(ids, f.FragID)
+	}
+	vecs, err := rag.PineconeFetch(ctx, cfg, ids)
+	if err != nil {
+		return nil, Upstream(err)
+	}
+	own := make([][]float32, 0, len(vecs))
+	sources := make([]model.Fragment, 0, len(vecs))
+	for _, f := range frags {
+		if v, ok := vecs[f.FragID]; ok {
+			own = append(own, v)
+			sources = append(sources, f)
+		}
+	}
+	centroid := rag.Centroid(own)
+	if centroid == nil {
+		// 笔记尚未完成向量化，不缓存，稍后再查即可得到结果
+		return []RelatedNote{}, nil
+	}
+	resp, err := rag.PineconeQuery(ctx, cfg, rag.QueryReq{
+		TopK:            relatedCandidates,
+		Vector:          centroid,
+		Filter:          map[string]interface{}{"note_id": map[string]interface{}{"$ne": note.ID}},
+		IncludeMetadata: true,
+		IncludeValues:   true,
+	})
+	if err != nil {
+		return nil, Upstream(err)
+	}
+	groups := make(map[int64]*RelatedNote)
+	if resp != nil {
+		for _, m := range resp.Matches {
+			nid := m.NoteID()
+			if nid <= 0 || nid == note.ID || float64(m.Score) < cfg.SimilarityThreshold {
+				continue
+			}
+			g := groups[nid]
+			if g == nil {
+				g = &RelatedNote{ID: nid, Pairs: make([]FragmentPair, 0, relatedPairs)}
+				groups[nid] = g
+			}
+			g.Score = max(g.Score, m.Score)
+			g.Pairs = append(g.Pairs, pairOf(m, own, sources))
+		}
+	}
+	list, versions, err := s.attachNotes(groups)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if len(s.cache) >= relatedCacheSize {
+		for k := range s.cache {
+			delete(s.cache, k)
+			break
+		}
+	}
+	s.cache[note.ID] = &relatedEntry{version: note.UpdatedAt, versions: versions, list: list}
+	s.mu.Unlock()
+	return list, nil
+}
+
+// attachNotes 补全相关笔记的标题等信息，去掉已删除的笔记，按分数排序后截取 MaxRelatedLimit 条
+func (s *RelatedService) attachNotes(groups map[int64]*RelatedNote) ([]RelatedNote, map[int64]time.Time, error) {
+	ids := make([]int64, 0, len(groups))
+	for nid := range groups {
+		ids = append(ids, nid)
+	}
+	notes, err := s.repo.FindByIDs(ids)
+	if err != nil {
+		return nil, nil, Internal("查询相关笔记失败", err)
+	}
+	list := make([]RelatedNote, 0, len(notes))
+	for _, n := range notes {
+		if n.IsDeleted != 0 {
+			continue
+		}
+		g := groups[n.ID]
+		g.Title, g.Excerpt, g.UpdatedAt = n.Title, n.Excerpt, n.UpdatedAt
+		sort.SliceStable(g.Pairs, func(i, j int) bool { return g.Pairs[i].Score > g.Pairs[j].Score })
+		if len(g.Pairs) > relatedPairs {
+			g.Pairs = g.Pairs[:relatedPairs]
+		}
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].ID < list[j].ID
+	})
+	if len(list) > MaxRelatedLimit {
+		list = list[:MaxRelatedLimit]
+	}
+	versions := make(map[int64]time.Time, len(list))
+	for _, r := range list {
+		versions[r.ID] = r.UpdatedAt
+	}
+	return list, versions, nil
+}
+
+// pairOf 为命中的片段找出当前笔记中与之最相近的片段；没有向量值时只返回命中片段
+func pairOf(m rag.Match, own [][]float32, sources []model.Fragment) FragmentPair {
+	content, _ := m.Metadata["content"].(string)
+	p := FragmentPair{TargetFragID: m.ID, Target: content, Score: m.Score}
+	if len(m.Values) == 0 {
+		return p
+	}
+	best := float32(-2)
+	for i, v := range own {
+		if sim := rag.Cosine(m.Values, v); sim > best {
+			best = sim
+			p.SourceFragID, p.Source, p.Score = sources[i].FragID, sources[i].Content, sim
+		}
+	}
+	return p
+}
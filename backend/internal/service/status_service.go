@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"note-system/config"
+	"note-system/internal/es"
+	"note-system/internal/httpx"
+	"note-system/internal/model"
+	"note-system/internal/rag"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 依赖状态
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDisabled = "disabled" // 未配置，对应功能以降级方式运行
+	StatusDegraded = "degraded"
+	StatusOK       = "ok"
+)
+
+// 单个依赖探测的超时
+const probeTimeout = 3 * time.Second
+
+// 状态结果的缓存时间，避免前端轮询时频繁探测外部服务
+const statusCacheTTL = 5 * time.Second
+
+// DependencyStatus 单个外部依赖的探测结果
+type DependencyStatus struct {
+	Name      string                 `json:"name"`
+	Status    string                 `json:"status"`
+	LatencyMS int64                  `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Info      map[string]interface{} `json:"info,omitempty"`
+}
+
+// Drift 数据库与外部索引之间的数量差异，非零说明需要重建索引
+type Drift struct {
+	Notes     int64  `json:"notes"`
+	ESDocs    *int64 `json:"es_docs,omitempty"`
+	Fragments int64  `json:"fragments"`
+	Vectors   *int64 `json:"vectors,omitempty"`
+}
+
+// SystemStatus 系统整体状态
+type SystemStatus struct {
+	// Status ok：全部可用；degraded：部分功能降级；down：数据库不可用
+	Status string `json:"status"`
+	// FullTextSearch 为 false 时搜索回退到数据库 LIKE
+	FullTextSearch bool `json:"full_text_search"`
+	// SemanticSearch 为 false 时语义搜索与问答不可用，前端可提示“语义搜索离线”
+	SemanticSearch bool               `json:"semantic_search"`
+	Dependencies   []DependencyStatus `json:"dependencies"`
+	Drift          *Drift             `json:"drift,omitempty"`
+	CheckedAt      time.Time          `json:"checked_at"`
+}
+
+// StatusService 探测数据库、ES、向量库、向量化服务与 LLM 的可用性
+type StatusService struct {
+	db  *gorm.DB
+	es  *es.Client
+	cfg *config.Holder
+	// probe 用于 LLM 连通性探测，不重试、不熔断
+	probe *httpx.Client
+
+	mu     sync.Mutex
+	cached *SystemStatus
+}
+
+func NewStatusService(db *gorm.DB, esClient *es.Client, cfg *config.Holder) *StatusService {
+	return &StatusService{
+		db:    db,
+		es:    esClient,
+		cfg:   cfg,
+		probe: httpx.New(httpx.Options{Name: "llm", Timeout: probeTimeout, MaxRetries: -1, BreakerThreshold: -1}),
+	}
+}
+
+// Ready 数据库可用即视为可以接收请求
+func (s *StatusService) Ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Status 并发探测全部依赖，结果缓存 statusCacheTTL
+func (s *StatusService) Status(ctx context.Context) *SystemStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && time.Since(s.cached.CheckedAt) < statusCacheTTL {
+		return s.cached
+	}
+
+	// 结果会被缓存，不能因为某个调用方断开而记录一次失败的探测
+	ctx = context.WithoutCancel(ctx)
+	cfg := s.cfg.Get()
+	var (
+		wg                sync.WaitGroup
+		dbSt, esSt, vecSt DependencyStatus
+		embedSt, llmSt    DependencyStatus
+		notes, frags      int64
+		esDocs, vectors   *int64
+		dbErr             error
+	)
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	run(func() { dbSt, notes, frags, dbErr = s.checkDB(ctx, cfg) })
+	run(func() { esSt, esDocs = s.checkES(ctx) })
+	run(func() { vecSt, vectors = s.checkVectors(ctx, cfg.Rag) })
+	run(func() { embedSt = s.checkEmbedder(ctx, cfg.Rag) })
+	run(func() { llmSt = s.checkLLM(ctx, cfg.LLM) })
+	wg.Wait()
+
+	st := &SystemStatus{
+		Dependencies:   []DependencyStatus{dbSt, esSt, vecSt, embedSt, llmSt},
+		FullTextSearch: esSt.Status == StatusUp,
+		SemanticSearch: vecSt.Status == StatusUp && embedSt.Status == StatusUp,
+		CheckedAt:      time.Now(),
+	}
+	switch {
+	case dbErr != nil:
+		st.Status = StatusDown
+	case esSt.Status != StatusUp || vecSt.Status != StatusUp || embedSt.Status != StatusUp || llmSt.Status == StatusDown:
+		st.Status = StatusDegraded
+	default:
+		st.Status = StatusOK
+	}
+	if dbErr == nil {
+		st.Drift = &Drift{Notes: notes, ESDocs: esDocs, Fragments: frags, Vectors: vectors}
+	}
+	s.cached = st
+	return st
+}
+
+// timed 执行一次带超时的探测并记录耗时
+func timed(ctx context.Context, name string, fn func(ctx context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx)
+	st := DependencyStatus{Name: name, Status: StatusUp, LatencyMS: time.Since(start).Milliseconds(), Info: map[string]interface{}{}}
+	if err != nil {
+		st.Status = StatusDown
+		st.Error = err.Error()
+	}
+	return st
+}
+
+func (s *StatusService) checkDB(ctx context.Context, cfg *config.Config) (DependencyStatus, int64, int64, error) {
+	var notes, frags int64
+	var pingErr error
+	st := timed(ctx, "database", func(ctx context.Context) error {
+		sqlDB, err := s.db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		pingErr = err
+		return err
+	})
+	st.Info["driver"] = cfg.Database.Driver
+	if pingErr != nil {
+		return st, 0, 0, pingErr
+	}
+	db := s.db.WithContext(ctx)
+	_ = db.Model(&model.Note{}).Where("is_deleted = 0").Count(&notes).Error
+	_ = db.Model(&model.Fragment{}).Count(&frags).Error
+	return st, notes, frags, nil
+}
+
+func (s *StatusService) checkES(ctx context.Context) (DependencyStatus, *int64) {
+	var count int64
+	st := timed(ctx, "es", func(ctx context.Context) error {
+		n, err := s.es.Count(ctx)
+		count = n
+		return err
+	})
+	if st.Status != StatusUp {
+		return st, nil
+	}
+	return st, &count
+}
+
+func (s *StatusService) checkVectors(ctx context.Context, cfg config.RagConfig) (DependencyStatus, *int64) {
+	if !rag.PineconeEnabled(cfg) {
+		return DependencyStatus{Name: "vector_store", Status: StatusDisabled, Error: "未配置 Pinecone，语义检索不可用"}, nil
+	}
+	var stats *rag.IndexStats
+	st := timed(ctx, "vector_store", func(ctx context.Context) error {
+		var err error
+		stats, err = rag.PineconeStats(ctx, cfg)
+		return err
+	})
+	st.Info["index"] = cfg.PineconeIndex
+	if st.Status != StatusUp || stats == nil {
+		return st, nil
+	}
+	st.Info["dimension"] = stats.Dimension
+	if stats.Dimension != 0 && stats.Dimension != cfg.EmbedDim {
+		st.Status = StatusDegraded
+		st.Error = "索引维度与 rag.embed_dim 不一致"
+	}
+	return st, &stats.TotalVectorCount
+}
+
+func (s *StatusService) checkEmbedder(ctx context.Context, cfg config.RagConfig) DependencyStatus {
+	st := timed(ctx, "embedder", func(ctx context.Context) error {
+		vecs, err := rag.EmbedBatch(ctx, cfg, []string{"ping"})
+		if err == nil && (len(vecs) != 1 || len(vecs[0]) != cfg.EmbedDim) {
+			err = errors.New("向量维度与 rag.embed_dim 不一致")
+		}
+		return err
+	})
+	st.Info["dimension"] = cfg.EmbedDim
+	if cfg.EmbeddingURL == "" {
+		// 本地哈希向量不具备语义，只保证流程可用
+		st.Status = StatusDegraded
+		st.Error = "未配置 embedding_url，使用本地哈希向量"
+		st.Info["model"] = "local-hash"
+	}
+	return st
+}
+
+func (s *StatusService) checkLLM(ctx context.Context, cfg config.LLMConfig) DependencyStatus {
+	if cfg.URL == "" {
+		return DependencyStatus{Name: "llm", Status: StatusDisabled, Error: "未配置 llm.url，问答直接返回检索片段"}
+	}
+	st := timed(ctx, "llm", func(ctx context.Context) error {
+		// 只探测连通性：对话接口对 GET 返回 4xx 也说明服务在线
+		_, err := s.probe.Do(ctx, httpx.Request{Op: "probe", Method: http.MethodGet, URL: cfg.URL})
+		var se *httpx.StatusError
+		if errors.As(err, &se) && se.StatusCode < 500 {
+			return nil
+		}
+		return err
+	})
+	st.Info["model"] = cfg.Model
+	return st
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"note-system/config"
+	"note-system/internal/common"
+	"note-system/internal/llm"
+	"note-system/internal/logging"
+	"note-system/internal/model"
+	"note-system/internal/repository"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// 送入 LLM 的正文上限（字），超出部分截断
+	summaryInputRunes = 4000
+	// 排队等待生成摘要的笔记数上限，队列满时丢弃，下次保存或手动触发时再生成
+	summaryQueueSize = 256
+	maxSuggestedTags = 5
+	// 摘要与标签的输出都很短，限制 token 数避免模型跑题时长时间生成
+	summaryMaxTokens = 512
+)
+
+// SummaryService 调用 LLM 为笔记生成摘要、建议标题与标签。
+// 保存笔记后通过 Enqueue 排队由后台 worker 生成（需开启 llm.summary_enabled），也可通过 Summarize 手动触发
+type SummaryService struct {
+	repo  repository.NoteRepository
+	rag   *RAGService
+	llm   llm.ChatClient
+	cfg   *config.Holder
+	queue chan int64
+
+	mu      sync.Mutex
+	pending map[int64]struct{}
+}
+
+func NewSummaryService(repo repository.NoteRepository, rag *RAGService, chat llm.ChatClient, cfg *config.Holder) *SummaryService {
+	return &SummaryService{
+		repo:    repo,
+		rag:     rag,
+		llm:     chat,
+		cfg:     cfg,
+		queue:   make(chan int64, summaryQueueSize),
+		pending: make(map[int64]struct{}),
+	}
+}
+
+// AutoEnabled 是否在保存笔记后自动生成摘要
+func (s *SummaryService) AutoEnabled() bool {
+	return s.cfg.Get().LLM.SummaryEnabled && s.llm.Enabled()
+}
+
+// Enqueue 摘要缺失或已过期时把笔记加入后台队列；未开启自动摘要、已在队列中或队列已满时直接返回
+func (s *SummaryService) Enqueue(note *model.Note) {
+	if note == nil || !s.AutoEnabled() || note.SummaryFresh() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[note.ID]; ok {
+		return
+	}
+	select {
+	case s.queue <- note.ID:
+		s.pending[note.ID] = struct{}{}
+	default:
+		slog.Debug("摘要队列已满，跳过", "note_id", note.ID)
+	}
+}
+
+// Run 后台 worker：逐条生成队列中笔记的摘要，stop 关闭后处理完当前一条即返回
+func (s *SummaryService) Run(ctx context.Context, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case id := <-s.queue:
+			s.mu.Lock()
+			delete(s.pending, id)
+			s.mu.Unlock()
+			note, err := s.repo.GetByID(id)
+			if err != nil || note.SummaryFresh() {
+				// 笔记已删除，或已通过手动触发生成
+				continue
+			}
+			_, err = s.summarize(ctx, note)
+			logging.WarnIf(ctx, err, "生成笔记摘要失败", "note_id", id)
+		}
+	}
+}
+
+// Summarize 立即为笔记生成摘要，不受 llm.summary_enabled 限制
+func (s *SummaryService) Summarize(ctx context.Context, id int64) (*model.Note, error) {
+	if id <= 0 {
+		return nil, Validation(common.CodeInvalidID)
+	}
+	if !s.llm.Enabled() {
+		return nil, Unavailable(common.CodeLLMDisabled)
+	}
+	note, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, dbError("查询笔记失败", err, common.CodeNoteNotFound)
+	}
+	return s.summarize(ctx, note)
+}
+
+// summaryResult LLM 按提示词返回的 JSON；summary 与 tagging 模板各自只填其中一部分
+type summaryResult struct {
+	Title   string   `json:"title"`
+	Summary string   `json:"summary"`
+	Tags    []string `json:"tags"`
+}
+
+// summarize 先按 summary 模板生成摘要与建议标题，再按 tagging 模板生成标签；
+// 标签生成失败只记录日志，保留摘要。模型在一次回答中顺带给出标签时沿用
+func (s *SummaryService) summarize(ctx context.Context, note *model.Note) (*model.Note, error) {
+	data := llm.NoteData{Title: note.Title, Content: truncateRunes(note.Content, summaryInputRunes), MaxTags: maxSuggestedTags}
+	res, err := s.complete(ctx, llm.PromptSummary, data)
+	if err != nil {
+		return nil, err
+	}
+	if res.Summary == "" {
+		return nil, Upstream(errors.New("LLM 返回的摘要为空"))
+	}
+	if len(res.Tags) == 0 {
+		tagged, err := s.complete(ctx, llm.PromptTagging, data)
+		logging.WarnIf(ctx, err, "生成建议标签失败", "note_id", note.ID)
+		if err == nil {
+			res.Tags = tagged.Tags
+		}
+	}
+
+	// 记录摘要对应的笔记版本，生成期间笔记被修改时摘要会被判定为过期
+	version := note.UpdatedAt
+	note.Summary = res.Summary
+	note.SuggestedTitle = res.Title
+	note.SuggestedTags = res.Tags
+	note.SummarizedAt = &version
+	if err := s.repo.UpdateAISummary(note); err != nil {
+		return nil, Internal("保存笔记摘要失败", err)
+	}
+	if s.rag != nil {
+		logging.WarnIf(ctx, s.rag.UpdateNoteSummary(ctx, note), "更新向量元数据失败", "note_id", note.ID)
+	}
+	return note, nil
+}
+
+// complete 渲染 prompt 模板并以 JSON 模式调用 LLM
+func (s *SummaryService) complete(ctx context.Context, prompt string, data llm.NoteData) (summaryResult, error) {
+	msgs, err := llm.Render(s.cfg.Get().LLM.PromptDir, prompt, data)
+	if err != nil {
+		return summaryResult{}, Internal("渲染提示词失败", err)
+	}
+	temperature := 0.0
+	resp, err := s.llm.Chat(ctx, llm.ChatRequest{Op: prompt, Messages: msgs, MaxTokens: summaryMaxTokens, Temperature: &temperature, JSON: true})
+	if err != nil {
+		return summaryResult{}, Upstream(err)
+	}
+	return parseSummary(resp.Content), nil
+}
+
+// parseSummary 解析 LLM 输出；不是合法 JSON 时把整段文字当作摘要
+func parseSummary(text string) summaryResult {
+	text = strings.TrimSpace(text)
+	var res summaryResult
+	if i, j := strings.Index(text, "{"), strings.LastIndex(text, "}"); i >= 0 && j > i {
+		if json.Unmarshal([]byte(text[i:j+1]), &res) != nil {
+			res = summaryResult{}
+		}
+	}
+	if res.Summary == "" && res.Title == "" && len(res.Tags) == 0 {
+		res.Summary = text
+	}
+	res.Summary = truncateRunes(strings.TrimSpace(res.Summary), 500)
+	res.Title = truncateRunes(strings.TrimSpace(res.Title), 200)
+	res.Tags = normalizeTags(res.Tags)
+	if len(res.Tags) > maxSuggestedTags {
+		res.Tags = res.Tags[:maxSuggestedTags]
+	}
+	return res
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
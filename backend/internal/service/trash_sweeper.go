@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"note-system/internal/es"
+	"note-system/internal/logging"
+	"time"
+)
+
+// TrashSweeper 后台定期清理超过保留期的回收站笔记，连同片段、ES 文档、向量、链接与附件一起删除
+type TrashSweeper struct {
+	svc      NoteService
+	rag      *RAGService
+	links    *LinkService
+	atts     *AttachmentService
+	es       *es.Client
+	interval time.Duration
+}
+
+func NewTrashSweeper(svc NoteService, rag *RAGService, links *LinkService, atts *AttachmentService, esClient *es.Client, interval time.Duration) *TrashSweeper {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &TrashSweeper{svc: svc, rag: rag, links: links, atts: atts, es: esClient, interval: interval}
+}
+
+// Run 定期执行清理，启动时先执行一次；stop 关闭后在当前批次处理完后返回。
+// 作为 worker.Group 的任务运行
+func (s *TrashSweeper) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Sweep(ctx, stop)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sweep 执行一轮清理，返回被物理删除的笔记数。每批删除后立即同步外部索引，
+// stop 关闭时在批次之间退出，剩余笔记留到下次启动后清理
+func (s *TrashSweeper) Sweep(ctx context.Context, stop <-chan struct{}) int {
+	total := 0
+	for {
+		ids, err := s.svc.PurgeExpiredTrash(time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "回收站清理失败", "err", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		s.purgeIndexes(ctx, ids)
+		total += len(ids)
+		select {
+		case <-stop:
+			slog.InfoContext(ctx, "回收站清理中断", "purged", total)
+			return total
+		default:
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	if s.atts != nil {
+		_, err := s.atts.CollectGarbage()
+		logging.WarnIf(ctx, err, "回收附件文件失败")
+	}
+	slog.InfoContext(ctx, "回收站清理完成", "purged", total)
+	return total
+}
+
+func (s *TrashSweeper) purgeIndexes(ctx context.Context, ids []int64) {
+	logging.WarnIf(ctx, s.es.DeleteNotes(ctx, ids), "ES 删除过期笔记失败", "note_ids", ids)
+	if s.rag != nil {
+		logging.WarnIf(ctx, s.rag.PurgeNotes(ctx, ids), "清理过期笔记片段与向量失败", "note_ids", ids)
+	}
+	if s.links != nil {
+		logging.WarnIf(ctx, s.links.RemoveNotes(ids, true), "删除过期笔记链接失败", "note_ids", ids)
+	}
+}
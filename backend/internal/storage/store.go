@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore 附件内容的存储后端，key 为内容的 sha256 十六进制串
+type BlobStore interface {
+	Put(key string, r io.Reader, size int64) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
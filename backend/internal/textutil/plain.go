@@ -0,0 +1,84 @@
+// Package textutil 把 Markdown 笔记渲染为纯文本，用于生成摘要与统计字数
+package textutil
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	fenceRe    = regexp.MustCompile("(?s)```.*?```")
+	imageRe    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRe     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	wikiRe     = regexp.MustCompile(`\[\[([^\]|]+)(?:\|([^\]]+))?\]\]`)
+	htmlTagRe  = regexp.MustCompile(`<[^>]+>`)
+	lineMarkRe = regexp.MustCompile(`(?m)^\s{0,3}(?:#{1,6}\s+|>\s?|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)`)
+	ruleRe     = regexp.MustCompile(`(?m)^\s{0,3}(?:[-*_]\s*){3,}$`)
+	emphRe     = regexp.MustCompile("[*_~`]+")
+	spaceRe    = regexp.MustCompile(`\s+`)
+)
+
+// Plain 渲染后的纯文本：去掉标记符号，链接保留文字，keepCode 为 false 时丢弃代码块
+func Plain(md string, keepCode bool) string {
+	if keepCode {
+		md = fenceRe.ReplaceAllStringFunc(md, func(block string) string {
+			block = strings.TrimPrefix(block, "```")
+			block = strings.TrimSuffix(block, "```")
+			// 去掉语言标记所在的首行
+			if i := strings.IndexByte(block, '\n'); i >= 0 {
+				block = block[i+1:]
+			}
+			return "\n" + block + "\n"
+		})
+	} else {
+		md = fenceRe.ReplaceAllString(md, "\n")
+	}
+	md = imageRe.ReplaceAllString(md, "$1")
+	md = linkRe.ReplaceAllString(md, "$1")
+	md = wikiRe.ReplaceAllStringFunc(md, func(s string) string {
+		m := wikiRe.FindStringSubmatch(s)
+		if m[2] != "" {
+			return m[2]
+		}
+		return m[1]
+	})
+	md = htmlTagRe.ReplaceAllString(md, "")
+	md = ruleRe.ReplaceAllString(md, "")
+	md = lineMarkRe.ReplaceAllString(md, "")
+	md = emphRe.ReplaceAllString(md, "")
+	return strings.TrimSpace(spaceRe.ReplaceAllString(md, " "))
+}
+
+// Excerpt 正文（不含代码块）纯文本的前 n 个字符，截断时以省略号结尾
+func Excerpt(md string, n int) string {
+	text := Plain(md, false)
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+// WordCount 字数：中日韩文字每字计 1，其余按连续的字母数字计为一个词
+func WordCount(md string) int {
+	count, inWord := 0, false
+	for _, r := range Plain(md, true) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		case r == '\'' || r == '-':
+			// 连字符与撇号不拆词
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
@@ -0,0 +1,48 @@
+package textutil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Terms 文本的检索词元：中日韩文字取相邻两字（单独一字时取该字），其他文字取小写的字母数字串。
+// 本地向量化、重复笔记检测的 MinHash 与回答依据核对共用，保证三者对文本的切分一致
+func Terms(text string) []string {
+	out := make([]string, 0)
+	var word []rune
+	var prev rune
+	// run 当前连续中日韩文字的字数
+	run := 0
+	flushWord := func() {
+		if len(word) > 0 {
+			out = append(out, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushRun := func() {
+		if run == 1 {
+			out = append(out, string(prev))
+		}
+		run, prev = 0, 0
+	}
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			flushWord()
+			if run > 0 {
+				out = append(out, string([]rune{prev, r}))
+			}
+			prev = r
+			run++
+			continue
+		}
+		flushRun()
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+		} else {
+			flushWord()
+		}
+	}
+	flushWord()
+	flushRun()
+	return out
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Group 管理后台协程的生命周期。
+//
+// 每个 worker 拿到两个信号：stop 在进程开始退出时关闭，worker 应在下一个检查点（一批工作完成后）返回；
+// ctx 只在宽限期耗尽、需要强制退出时取消，用于中断正在进行的外部调用。
+type Group struct {
+	ctx  context.Context
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewGroup ctx 为进程的根上下文，强制退出时由调用方取消
+func NewGroup(ctx context.Context) *Group {
+	return &Group{ctx: ctx, stop: make(chan struct{})}
+}
+
+// Go 启动一个 worker，panic 会被记录而不会拖垮进程
+func (g *Group) Go(name string, fn func(ctx context.Context, stop <-chan struct{})) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				slog.Error("后台任务异常退出", "worker", name, "panic", err)
+			}
+		}()
+		fn(g.ctx, g.stop)
+	}()
+}
+
+// Shutdown 通知全部 worker 停止并等待其返回；ctx 到期仍未全部返回时返回 ctx 的错误
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.once.Do(func() { close(g.stop) })
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,9 +1,9 @@
 import request from './request'
 
-// 获取笔记列表
-export function getNoteList(page = 1, size = 10) {
+// 获取笔记列表（游标分页：cursor 传上一页返回的 next_cursor）
+export function getNoteList(size = 20, cursor = '', params = {}) {
     return request.get('/note/list', {
-        params: { page, size }
+        params: { size, cursor: cursor || undefined, ...params }
     })
 }
 
@@ -32,8 +32,8 @@ export function deleteNote(id) {
     return request.delete(`/note/${id}`)
 }
 
-export function getTrashList(page = 1, size = 10) {
-    return request.get('/note/trash', { params: { page, size } })
+export function getTrashList(size = 20, cursor = '') {
+    return request.get('/note/trash', { params: { size, cursor: cursor || undefined } })
 }
 
 export function restoreNote(id) {
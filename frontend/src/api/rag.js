@@ -4,6 +4,7 @@ export function ragSearch(q, topK = 5) {
   return request.get('/rag/search', { params: { q, topK } })
 }
 
-export function ragQA(question, timeoutMs = 180000) {
-  return request.post('/rag/qa', { question }, { timeout: timeoutMs })
+// history: 之前的对话 [{ role: 'user' | 'assistant', content }]，用于改写依赖上下文的追问
+export function ragQA(question, history = [], timeoutMs = 180000) {
+  return request.post('/rag/qa', { question, history }, { timeout: timeoutMs })
 }